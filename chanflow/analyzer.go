@@ -0,0 +1,212 @@
+package chanflow
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/types/objectpath"
+)
+
+// Analyzer reports the diagnostics of every check on the channels of a
+// package, for use with singlechecker, multichecker, go vet -vettool,
+// gopls or golangci-lint. The operations a package performs on its
+// package-level channels and on the channel parameters and results of its
+// functions are exported as ChannelFacts, so that a close in a dependency
+// is weighed against the sends of the packages importing it.
+//
+// Rules are weighed as in Analyze: the -severity flag overrides their
+// severity, "off" disabling them, and only diagnostics at least as severe
+// as -min-severity are reported, as in
+//
+//	go vet -vettool=$(which chanflow) -severity=dangling=off -min-severity=warning ./...
+var Analyzer = &analysis.Analyzer{
+	Name:      "chanflow",
+	Doc:       "check channel usage for deadlocks, goroutine leaks and unsafe closes",
+	Run:       run,
+	FactTypes: []analysis.Fact{new(ChannelFact)},
+}
+
+// ChannelFact records the operations the declaring package performs on a
+// package-level channel variable, a struct field, or a parameter or result
+// of a package-level function.
+type ChannelFact struct {
+	Name       string
+	Capacities []string
+	SendOps    []Operation
+	ReceiveOps []Operation
+	CloseOps   []Operation
+	RangeOps   []Operation
+}
+
+// Flags of Analyzer.
+var (
+	severities  = severityFlag{}
+	minSeverity = SeverityNote
+)
+
+func init() {
+	Analyzer.Flags.Var(severities, "severity",
+		"override rule severities, as in deadlock=warning,dangling=off (error, warning, note or off)")
+	Analyzer.Flags.StringVar(&minSeverity, "min-severity", SeverityNote,
+		"lowest severity reported: error, warning or note")
+}
+
+// severityFlag is a flag.Value of comma-separated rule=severity pairs.
+type severityFlag map[string]string
+
+func (f severityFlag) String() string {
+	pairs := make([]string, 0, len(f))
+	for rule, severity := range f {
+		pairs = append(pairs, rule+"="+severity)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (f severityFlag) Set(value string) error {
+	for _, pair := range strings.Split(value, ",") {
+		rule, severity, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("%q is not rule=severity", pair)
+		}
+		if err := checkSeverities(map[string]string{rule: severity}); err != nil {
+			return err
+		}
+		f[rule] = severity
+	}
+	return nil
+}
+
+func (*ChannelFact) AFact() {}
+
+func (f *ChannelFact) String() string {
+	return fmt.Sprintf("channel %s: %d sends, %d receives, %d closes, %d range loops",
+		f.Name, len(f.SendOps), len(f.ReceiveOps), len(f.CloseOps), len(f.RangeOps))
+}
+
+func run(pass *analysis.Pass) (any, error) {
+	threshold := SeverityRank(minSeverity)
+	if threshold < 1 {
+		return nil, fmt.Errorf("unknown -min-severity %q: use error, warning or note", minSeverity)
+	}
+	channels := make(map[string]*ChannelInfo)
+	var enc objectpath.Encoder
+
+	// Channels of imported packages start out with the operations their
+	// own package performs on them.
+	importFact := func(obj *types.Var, name string) {
+		obj = obj.Origin()
+		if obj.Pkg() == nil || obj.Pkg() == pass.Pkg {
+			return
+		}
+		key := objectKey(pass.Fset, &enc, obj)
+		if _, ok := channels[key]; ok {
+			return
+		}
+		var fact ChannelFact
+		if !pass.ImportObjectFact(obj, &fact) {
+			return
+		}
+		if fact.Name != "" {
+			name = fact.Name
+		}
+		channel := newChannel(pass.Fset, obj, name, obj.Pos())
+		channels[key] = channel
+		channel.Capacities = fact.Capacities
+		channel.SendOps = append(channel.SendOps, fact.SendOps...)
+		channel.ReceiveOps = append(channel.ReceiveOps, fact.ReceiveOps...)
+		channel.CloseOps = append(channel.CloseOps, fact.CloseOps...)
+		channel.RangeOps = append(channel.RangeOps, fact.RangeOps...)
+	}
+	for _, obj := range pass.TypesInfo.Uses {
+		switch obj := obj.(type) {
+		case *types.Var:
+			importFact(obj, obj.Name())
+		case *types.Func:
+			sig := obj.Origin().Signature()
+			for _, tuple := range []*types.Tuple{sig.Params(), sig.Results()} {
+				for i := 0; i < tuple.Len(); i++ {
+					importFact(tuple.At(i), tuple.At(i).Name())
+				}
+			}
+		}
+	}
+
+	facts := make([]*fileFacts, len(pass.Files))
+	for i, file := range pass.Files {
+		facts[i] = analyzeFile(pass.Fset, pass.TypesInfo, file)
+	}
+	spawns, calls, flows := mergeFiles(channels, facts)
+	attributeGoroutines(channels, spawns, calls)
+
+	// Export facts before resolveFlows folds parameters into the channels
+	// passed to them in this package.
+	for _, f := range facts {
+		for _, d := range f.Decls {
+			v, ok := d.obj.(*types.Var)
+			channel := channels[d.Key]
+			if !ok || v.Pkg() != pass.Pkg || channel.Kind == KindLocal {
+				continue
+			}
+			pass.ExportObjectFact(v, &ChannelFact{
+				Name:       channel.Name,
+				Capacities: channel.Capacities,
+				SendOps:    channel.SendOps,
+				ReceiveOps: channel.ReceiveOps,
+				CloseOps:   channel.CloseOps,
+				RangeOps:   channel.RangeOps,
+			})
+		}
+	}
+
+	resolveFlows(channels, flows)
+	list := make([]*ChannelInfo, 0, len(channels))
+	for _, channel := range channels {
+		list = append(list, channel)
+	}
+	runChecks(list)
+	applySeverities(list, severities)
+
+	// An operation may be reached through several channels; report each
+	// diagnostic once. Diagnostics at operations of imported packages were
+	// reported when those packages were analyzed.
+	files := make(map[string]*token.File)
+	for _, file := range pass.Files {
+		tf := pass.Fset.File(file.Pos())
+		files[tf.Name()] = tf
+	}
+	reported := make(map[string]bool)
+	for _, channel := range channels {
+		for _, d := range channel.Diagnostics {
+			if SeverityRank(d.Severity) < threshold {
+				continue
+			}
+			if !d.pos.IsValid() || files[pass.Fset.File(d.pos).Name()] == nil || reported[d.String()] {
+				continue
+			}
+			reported[d.String()] = true
+			diag := analysis.Diagnostic{Pos: d.pos, Category: d.Rule, Message: d.Message}
+			for _, related := range d.Related {
+				if pos := posOf(files, related); pos.IsValid() {
+					diag.Related = append(diag.Related, analysis.RelatedInformation{Pos: pos, Message: "related operation"})
+				}
+			}
+			pass.Report(diag)
+		}
+	}
+	return nil, nil
+}
+
+// posOf returns the Pos of position in files, or token.NoPos when its file
+// is not among them.
+func posOf(files map[string]*token.File, position token.Position) token.Pos {
+	tf := files[position.Filename]
+	if tf == nil || position.Offset < 0 || position.Offset > tf.Size() {
+		return token.NoPos
+	}
+	return tf.Pos(position.Offset)
+}
@@ -0,0 +1,33 @@
+package chanflow_test
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+
+	"channeling/chanflow"
+)
+
+func TestDeadlock(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), chanflow.Analyzer, "deadlock")
+}
+
+func TestLeak(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), chanflow.Analyzer, "leak")
+}
+
+func TestCloses(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), chanflow.Analyzer, "closes")
+}
+
+func TestOwnership(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), chanflow.Analyzer, "ownership")
+}
+
+func TestFacts(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), chanflow.Analyzer, "facts/use")
+}
+
+func TestUsage(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), chanflow.Analyzer, "usage")
+}
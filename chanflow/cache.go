@@ -0,0 +1,295 @@
+package chanflow
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// cacheFormat is bumped whenever the encoding of cached facts changes.
+const cacheFormat = 9
+
+// DefaultCacheDir returns the directory analysis results are cached in
+// unless another one is given in Options.CacheDir: channeling in the user
+// cache directory.
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "channeling"), nil
+}
+
+// CleanCache removes the cache directory dir and everything in it.
+func CleanCache(dir string) error {
+	return os.RemoveAll(dir)
+}
+
+// fileCache keeps the facts of the files of a package in dir, under a key
+// naming the package, the content of its files and of its dependencies, the
+// Go version and the version of the tool.
+type fileCache struct {
+	dir string
+}
+
+func (c *fileCache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key)
+}
+
+// get returns the facts stored under key, if any.
+func (c *fileCache) get(key string) ([]*fileFacts, bool) {
+	f, err := os.Open(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+	var facts []*fileFacts
+	if err := gob.NewDecoder(bufio.NewReader(f)).Decode(&facts); err != nil {
+		return nil, false
+	}
+	// Gob decodes empty lists as nil; channels start with empty ones.
+	for _, file := range facts {
+		for _, d := range file.Decls {
+			for _, ops := range []*[]Operation{
+				&d.Channel.MakeOps, &d.Channel.SendOps, &d.Channel.ReceiveOps, &d.Channel.CloseOps,
+				&d.Channel.RangeOps, &d.Channel.ReturnedFrom, &d.Channel.PassedTo,
+			} {
+				if *ops == nil {
+					*ops = []Operation{}
+				}
+			}
+		}
+	}
+	return facts, true
+}
+
+// put stores facts under key. Entries are written to a temporary file and
+// renamed into place so that concurrent runs never read a partial one.
+func (c *fileCache) put(key string, facts []*fileFacts) error {
+	name := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(name), key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	err = gob.NewEncoder(w).Encode(facts)
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), name)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// packageKeys returns the cache keys of pkgs and of their dependencies by
+// package ID. A key hashes the compiled Go files of the package, the keys
+// of its imports, the Go version of the package and of the tool, and the
+// tool itself, so that it changes with any file the type-checking of the
+// package depends on.
+func packageKeys(pkgs []*packages.Package) (map[string]string, error) {
+	keys := make(map[string]string)
+	files := make(map[string]string)
+	var err error
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		if err != nil {
+			return
+		}
+		h := sha256.New()
+		fmt.Fprintf(h, "channeling cache %d\n%s\n%s\n%s\n", cacheFormat, toolVersion(), runtime.Version(), pkg.ID)
+		if pkg.Module != nil {
+			fmt.Fprintf(h, "go %s\n", pkg.Module.GoVersion)
+		}
+		for _, name := range pkg.CompiledGoFiles {
+			sum, ok := files[name]
+			if !ok {
+				if sum, err = hashFile(name); err != nil {
+					return
+				}
+				files[name] = sum
+			}
+			fmt.Fprintf(h, "file %s %s\n", name, sum)
+		}
+		paths := make([]string, 0, len(pkg.Imports))
+		for path := range pkg.Imports {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			fmt.Fprintf(h, "import %s %s\n", path, keys[pkg.Imports[path].ID])
+		}
+		keys[pkg.ID] = hex.EncodeToString(h.Sum(nil))
+	})
+	return keys, err
+}
+
+// entryKey is the key of the facts of the files of the package with the
+// given key, which are those of the package not excluded from analysis,
+// generated files included or not.
+func entryKey(pkgKey string, files []string, generated bool) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\ngenerated %t\n%s\n", pkgKey, generated, strings.Join(files, "\n"))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func hashFile(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// toolVersion identifies the running program by the hash of its
+// executable, so that rebuilding it with a changed analysis invalidates the
+// cache, or by its module version if the executable cannot be read.
+var toolVersion = sync.OnceValue(func() string {
+	if exe, err := os.Executable(); err == nil {
+		if sum, err := hashFile(exe); err == nil {
+			return sum
+		}
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		return info.Main.Path + "@" + info.Main.Version
+	}
+	return "unknown"
+})
+
+// analyzeCached is analyzeSyntax with the facts of unchanged packages taken
+// from the cache in opts.CacheDir. The packages matching patterns are first
+// listed without being type-checked; only those missing from the cache are
+// then loaded and analyzed, and their facts stored. Packages with errors
+// are never cached.
+func analyzeCached(cfg *packages.Config, patterns []string, opts Options, t *tracker) ([]*ChannelInfo, []PackageError, error) {
+	ctx := cfg.Context
+	list := *cfg
+	list.Mode = packages.NeedName | packages.NeedFiles | packages.NeedCompiledGoFiles |
+		packages.NeedImports | packages.NeedDeps | packages.NeedModule
+	list.ParseFile = nil
+	roots, err := packages.Load(&list, patterns...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("loading packages: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	if opts.Tests {
+		roots = testVariants(roots)
+	}
+	keys, err := packageKeys(roots)
+	if err != nil {
+		return nil, nil, err
+	}
+	root, err := filepath.Abs(opts.Dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c := &fileCache{dir: opts.CacheDir}
+	facts := make([][]*fileFacts, len(roots))
+	entries := make([]string, len(roots))
+	missing := make(map[string]int)
+	var paths []string
+	for i, pkg := range roots {
+		var files []string
+		for _, name := range pkg.CompiledGoFiles {
+			if !excluded(root, name, opts.Exclude) {
+				files = append(files, name)
+			}
+		}
+		entries[i] = entryKey(keys[pkg.ID], files, opts.Generated)
+		if len(pkg.Errors) == 0 {
+			if f, ok := c.get(entries[i]); ok {
+				facts[i] = f
+				continue
+			}
+		}
+		missing[pkg.ID] = i
+		paths = appendIfNotExists(paths, listPath(pkg))
+	}
+	for _, path := range paths {
+		// Packages of files named on the command line have no path to
+		// load them by.
+		if path == "command-line-arguments" {
+			paths = patterns
+			break
+		}
+	}
+	t.update(func(p *Progress) { p.Cached = len(roots) - len(missing) })
+
+	fresh := make([]*packages.Package, len(roots))
+	if len(missing) > 0 {
+		pkgs, err := loadPackages(cfg, paths, opts, t)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var load []*packages.Package
+		var index []int
+		for _, pkg := range pkgs {
+			if i, ok := missing[pkg.ID]; ok && fresh[i] == nil {
+				fresh[i] = pkg
+				load = append(load, pkg)
+				index = append(index, i)
+			}
+		}
+		analyzed, err := collectFacts(ctx, cfg.Fset, load, opts.Workers, t)
+		if err != nil {
+			return nil, nil, err
+		}
+		for j, pkg := range load {
+			facts[index[j]] = analyzed[j]
+			if len(pkg.Errors) == 0 && pkg.TypesInfo != nil {
+				// The cache is best effort: failing to fill it only
+				// costs the next run time.
+				c.put(entries[index[j]], analyzed[j])
+			}
+		}
+	}
+
+	var errs []PackageError
+	var files []*fileFacts
+	for i, pkg := range roots {
+		files = append(files, facts[i]...)
+		if fresh[i] != nil {
+			pkg = fresh[i]
+		}
+		errs = append(errs, packageErrors([]*packages.Package{pkg})...)
+	}
+	return resolveFiles(files), errs, nil
+}
+
+// listPath returns the path pkg is loaded by, which for the test variants
+// "p [p.test]" and "p_test [p.test]" is p.
+func listPath(pkg *packages.Package) string {
+	if i := strings.Index(pkg.ID, " ["); i >= 0 && strings.HasSuffix(pkg.ID, ".test]") {
+		return strings.TrimSuffix(pkg.ID[i+2:], ".test]")
+	}
+	return pkg.PkgPath
+}
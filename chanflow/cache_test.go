@@ -0,0 +1,155 @@
+package chanflow_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"channeling/chanflow"
+)
+
+// cacheModule is a module of three packages: b imports a, c stands alone.
+var cacheModule = map[string]string{
+	"go.mod": "module example.com/m\n\ngo 1.22\n",
+	"a/a.go": `package a
+
+func Send(ch chan int) {
+	ch <- 1
+}
+`,
+	"b/b.go": `package b
+
+import "example.com/m/a"
+
+func Run() int {
+	ch := make(chan int, 1)
+	a.Send(ch)
+	return <-ch
+}
+`,
+	"c/c.go": `package c
+
+func Run() {
+	ch := make(chan int, 1)
+	ch <- 1
+}
+`,
+}
+
+// writeModule writes files to a new temporary directory and returns it.
+func writeModule(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		writeFile(t, filepath.Join(dir, name), content)
+	}
+	return dir
+}
+
+func writeFile(t *testing.T, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// analyzeCounting runs Analyze over every package of dir and returns the
+// report as JSON with the number of packages taken from the cache.
+func analyzeCounting(t *testing.T, dir, cacheDir string) (report string, cached int) {
+	t.Helper()
+	r, err := chanflow.Analyze(context.Background(), nil, chanflow.Options{
+		Dir:      dir,
+		CacheDir: cacheDir,
+		Progress: func(p chanflow.Progress) { cached = p.Cached },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out), cached
+}
+
+func TestCacheMatchesUncached(t *testing.T) {
+	dir := writeModule(t, cacheModule)
+	cacheDir := t.TempDir()
+
+	want, _ := analyzeCounting(t, dir, "")
+	cold, cached := analyzeCounting(t, dir, cacheDir)
+	if cached != 0 {
+		t.Errorf("cold run took %d packages from the cache, want 0", cached)
+	}
+	if cold != want {
+		t.Errorf("cold cached run:\n%s\nwant:\n%s", cold, want)
+	}
+	warm, cached := analyzeCounting(t, dir, cacheDir)
+	if cached != 3 {
+		t.Errorf("warm run took %d packages from the cache, want 3", cached)
+	}
+	if warm != want {
+		t.Errorf("warm cached run:\n%s\nwant:\n%s", warm, want)
+	}
+}
+
+func TestCacheInvalidatesImporters(t *testing.T) {
+	dir := writeModule(t, cacheModule)
+	cacheDir := t.TempDir()
+	analyzeCounting(t, dir, cacheDir)
+
+	// Send no longer sends, so the channel of b is never sent on.
+	writeFile(t, filepath.Join(dir, "a", "a.go"), `package a
+
+func Send(ch chan int) {
+}
+`)
+	got, cached := analyzeCounting(t, dir, cacheDir)
+	if cached != 1 {
+		t.Errorf("took %d packages from the cache after editing a, want 1 (c)", cached)
+	}
+	want, _ := analyzeCounting(t, dir, "")
+	if got != want {
+		t.Errorf("cached run after editing a:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCacheSkipsPackagesWithErrors(t *testing.T) {
+	files := map[string]string{
+		"d/d.go": `package d
+
+func Run() {
+	ch := make(chan int, 1)
+	ch <- undefined
+}
+`,
+	}
+	for name, content := range cacheModule {
+		files[name] = content
+	}
+	dir := writeModule(t, files)
+	cacheDir := t.TempDir()
+
+	for run := range 2 {
+		r, err := chanflow.Analyze(context.Background(), nil, chanflow.Options{
+			Dir:      dir,
+			CacheDir: cacheDir,
+			Progress: func(p chanflow.Progress) {
+				if run == 1 && p.Stage == chanflow.StageDone && p.Cached != 3 {
+					t.Errorf("took %d packages from the cache, want 3 (all but d)", p.Cached)
+				}
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(r.PackageErrors) == 0 {
+			t.Errorf("run %d: no package errors for d", run)
+		}
+	}
+}
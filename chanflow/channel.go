@@ -0,0 +1,128 @@
+// Package chanflow finds the channels declared in Go packages, follows
+// them through assignments, calls, returns and struct fields to the
+// operations performed on them, and checks how they are used.
+package chanflow
+
+import (
+	"go/token"
+	"slices"
+)
+
+// Channel declaration kinds reported in ChannelInfo.Kind.
+const (
+	KindLocal      = "local"
+	KindPackageVar = "package var"
+	KindField      = "field"
+	KindParam      = "param"
+	KindResult     = "result"
+)
+
+// Channel directions reported in ChannelInfo.Direction.
+const (
+	DirBoth    = "bidirectional"
+	DirSend    = "send"
+	DirReceive = "receive"
+)
+
+type ChannelInfo struct {
+	ID           string
+	Name         string
+	Kind         string
+	Type         string
+	ElemType     string
+	Direction    string
+	Capacities   []string
+	Location     string
+	Position     token.Position
+	Declaration  string
+	Aliases      []string
+	MakeOps      []Operation
+	SendOps      []Operation
+	ReceiveOps   []Operation
+	CloseOps     []Operation
+	RangeOps     []Operation
+	ReturnedFrom []Operation
+	PassedTo     []Operation
+	UsedInFiles  []string
+	Parties      []Party
+	Owner        string
+	Diagnostics  []Diagnostic
+	pos          token.Pos
+}
+
+// clone returns a copy of c whose lists are clipped to their length, so
+// that appending to those of the copy leaves c as it is.
+func (c *ChannelInfo) clone() *ChannelInfo {
+	clone := *c
+	clone.Capacities = slices.Clip(c.Capacities)
+	clone.Aliases = slices.Clip(c.Aliases)
+	clone.MakeOps = slices.Clip(c.MakeOps)
+	clone.SendOps = slices.Clip(c.SendOps)
+	clone.ReceiveOps = slices.Clip(c.ReceiveOps)
+	clone.CloseOps = slices.Clip(c.CloseOps)
+	clone.RangeOps = slices.Clip(c.RangeOps)
+	clone.ReturnedFrom = slices.Clip(c.ReturnedFrom)
+	clone.PassedTo = slices.Clip(c.PassedTo)
+	clone.UsedInFiles = slices.Clip(c.UsedInFiles)
+	clone.Parties = slices.Clip(c.Parties)
+	clone.Diagnostics = slices.Clip(c.Diagnostics)
+	return &clone
+}
+
+// Operation is a single use of a channel. Func names the enclosing function
+// and Goroutine the spawn site of the goroutine running it, empty when it
+// runs on whichever goroutine calls Func. MultiInstance marks goroutines
+// that may run several times at once, started in a loop or from several go
+// statements. SelectCanGiveUp marks select cases whose select also has a
+// default, timeout or cancellation case, and ClosedAt the close of the
+// channel that precedes a send or close on every path through the function,
+// if any, or with MayBeClosed that may run before a close on some path.
+// Position is Location with its column, and GoroutinePosition the position
+// of the go statement at Goroutine.
+type Operation struct {
+	Location          string
+	Func              string
+	Goroutine         string
+	GoroutinePosition token.Position
+	MultiInstance     bool
+	Select            bool
+	SelectCanGiveUp   bool
+	ClosedAt          token.Position
+	MayBeClosed       bool
+	Position          token.Position
+	// fn is the objectKey of the function declaration the operation runs
+	// in, when go statements starting it decide its goroutine. external
+	// marks a pass to a function the channel is not followed into, which
+	// may send on it, receive from it or close it. shared marks an
+	// operation of a function called, directly or not, from a goroutine,
+	// which runs on every goroutine calling it. spawner names the function
+	// starting the goroutine running a send or receive when it may return
+	// without reaching any counterpart of it.
+	fn       string
+	external bool
+	shared   bool
+	spawner  string
+	pos      token.Pos
+}
+
+func (op Operation) String() string {
+	s := op.Location
+	if op.Select {
+		s += " (select)"
+	}
+	s += " in " + op.Func
+	if op.Goroutine != "" {
+		s += " (goroutine started at " + op.Goroutine + ")"
+	}
+	return s
+}
+
+// Actor names the goroutine or function performing op, as in "goroutine
+// started at main.go:12" or "main.worker". It is the name of the Party of
+// op and the node of op in the graphs.
+func (op Operation) Actor() string {
+	if op.Goroutine != "" {
+		return "goroutine started at " + op.Goroutine
+	}
+	return op.Func
+}
@@ -0,0 +1,70 @@
+package chanflow_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"channeling/chanflow"
+)
+
+func TestChannelTypes(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.22\n",
+		"p/p.go": `package p
+
+func Send(out chan<- int) {
+	out <- 1
+}
+
+func Receive(in <-chan int) int {
+	return <-in
+}
+
+func Buffer[T any](n int) T {
+	buf := make(chan T, n)
+	return <-buf
+}
+
+func Errors() {
+	errs := make(chan chan error)
+	go func() { errs <- make(chan error, 1) }()
+	<-errs
+}
+`,
+	})
+	want := map[string]struct {
+		typ, elem, dir string
+		capacities     []string
+	}{
+		"out":  {"chan<- int", "int", chanflow.DirSend, nil},
+		"in":   {"<-chan int", "int", chanflow.DirReceive, nil},
+		"buf":  {"chan T", "T", chanflow.DirBoth, []string{"n"}},
+		"errs": {"chan chan error", "chan error", chanflow.DirBoth, []string{"0"}},
+	}
+	for _, backend := range []string{chanflow.BackendAST, chanflow.BackendSSA} {
+		t.Run(backend, func(t *testing.T) {
+			r, err := chanflow.Analyze(context.Background(), nil, chanflow.Options{Dir: dir, Backend: backend})
+			if err != nil {
+				t.Fatal(err)
+			}
+			found := make(map[string]bool)
+			for _, c := range r.Channels {
+				w, ok := want[c.Name]
+				if !ok {
+					continue
+				}
+				found[c.Name] = true
+				if c.Type != w.typ || c.ElemType != w.elem || c.Direction != w.dir || !slices.Equal(c.Capacities, w.capacities) {
+					t.Errorf("channel %s: type %q, element %q, direction %q, capacities %q; want %q, %q, %q, %q",
+						c.Name, c.Type, c.ElemType, c.Direction, c.Capacities, w.typ, w.elem, w.dir, w.capacities)
+				}
+			}
+			for name := range want {
+				if !found[name] {
+					t.Errorf("no channel %s", name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,375 @@
+package chanflow
+
+import (
+	"fmt"
+	"go/token"
+	"strings"
+)
+
+// Diagnostic is a problem found on the channel with ID Channel. Location and
+// Position are those of the operation at fault and Related lists the
+// positions of the operations that explain it. Severity is that of Rule,
+// unless overridden in Options.Severities.
+type Diagnostic struct {
+	Rule     string
+	Severity string
+	Channel  string
+	Location string
+	Position token.Position
+	Message  string
+	Related  []token.Position
+	pos      token.Pos
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("[%s] %s: %s", d.Rule, d.Location, d.Message)
+}
+
+// runChecks resolves the ownership of channels, runs every check over them
+// and records the diagnostics on the channel they concern.
+func runChecks(channels []*ChannelInfo) {
+	for _, channel := range channels {
+		resolveOwnership(channel)
+		channel.Diagnostics = append(channel.Diagnostics, checkUsage(channel)...)
+		channel.Diagnostics = append(channel.Diagnostics, checkDeadlock(channel)...)
+		channel.Diagnostics = append(channel.Diagnostics, checkLeak(channel)...)
+		channel.Diagnostics = append(channel.Diagnostics, checkClose(channel)...)
+		channel.Diagnostics = append(channel.Diagnostics, checkOwnership(channel)...)
+		for i := range channel.Diagnostics {
+			channel.Diagnostics[i].Channel = channel.ID
+		}
+	}
+}
+
+// DiagnosticsFor returns the diagnostics of c reported by rule.
+func (c *ChannelInfo) DiagnosticsFor(rule string) []Diagnostic {
+	var diags []Diagnostic
+	for _, d := range c.Diagnostics {
+		if d.Rule == rule {
+			diags = append(diags, d)
+		}
+	}
+	return diags
+}
+
+// sameGoroutine reports whether a and b certainly run one after the other
+// on a single goroutine: both on the goroutine started at the same spawn
+// site, unless several instances of it may run at once, or both in the same
+// function on whichever goroutine calls it, unless goroutines call it too.
+func sameGoroutine(a, b Operation) bool {
+	if a.Goroutine != b.Goroutine {
+		return false
+	}
+	if a.Goroutine != "" {
+		return !a.MultiInstance && !b.MultiInstance
+	}
+	return a.Func == b.Func && !a.shared && !b.shared
+}
+
+// unbuffered reports whether every make site of channel creates an
+// unbuffered channel.
+func unbuffered(channel *ChannelInfo) bool {
+	if len(channel.Capacities) == 0 {
+		return false
+	}
+	for _, capacity := range channel.Capacities {
+		if capacity != "0" {
+			return false
+		}
+	}
+	return true
+}
+
+// checkUsage flags channels missing one side: nothing sends on, closes or
+// receives from a dangling channel, a receive-only channel is never sent
+// on or closed and a send-only channel is never received from. A close
+// counts as a send since it unblocks receivers, and a range loop as a
+// receive. Channels never made in the analyzed code come from code the
+// analysis does not see, a directional parameter is one side by design and
+// a function the channel is passed to but not followed into may use either
+// side, so none of them is flagged.
+func checkUsage(channel *ChannelInfo) []Diagnostic {
+	switch channel.Kind {
+	case KindParam:
+		if channel.Direction != DirBoth || len(channel.MakeOps) == 0 {
+			return nil
+		}
+	case KindResult, KindField, KindLocal:
+		if len(channel.MakeOps) == 0 {
+			return nil
+		}
+	}
+	for _, op := range channel.PassedTo {
+		if op.external {
+			return nil
+		}
+	}
+	sends := len(channel.SendOps) + len(channel.CloseOps)
+	receives := len(channel.ReceiveOps) + len(channel.RangeOps)
+
+	var rule, message string
+	switch {
+	case sends == 0 && receives == 0:
+		rule, message = RuleDangling, "no send, receive, close or range operations"
+	case sends == 0:
+		rule, message = RuleReceiveOnly, "no send or close operations"
+	case receives == 0:
+		rule, message = RuleSendOnly, "no receive operations"
+	default:
+		return nil
+	}
+	return []Diagnostic{{
+		Rule:     rule,
+		Location: channel.Location,
+		Position: channel.Position,
+		Message:  fmt.Sprintf("channel %s has %s", channel.Name, message),
+		pos:      channel.pos,
+	}}
+}
+
+// checkDeadlock flags operations on an unbuffered channel whose every
+// counterpart runs on the same goroutine: a send there can only complete
+// once that goroutine receives, which it never reaches, and vice versa.
+// Channels without any counterpart are left to the send-only and
+// receive-only classification.
+func checkDeadlock(channel *ChannelInfo) []Diagnostic {
+	if !unbuffered(channel) {
+		return nil
+	}
+	receives := append(append([]Operation{}, channel.ReceiveOps...), channel.RangeOps...)
+	unblocks := append(append([]Operation{}, channel.SendOps...), channel.CloseOps...)
+
+	var diags []Diagnostic
+	diags = append(diags, blockedOps(channel, "send on", channel.SendOps, receives, "receive")...)
+	diags = append(diags, blockedOps(channel, "receive from", receives, unblocks, "send or close")...)
+	return diags
+}
+
+// blockedOps reports the operations in ops that no operation in
+// counterparts can complete concurrently. Operations in a select are
+// skipped since another case may proceed.
+func blockedOps(channel *ChannelInfo, verb string, ops, counterparts []Operation, counterpart string) []Diagnostic {
+	if len(counterparts) == 0 {
+		return nil
+	}
+	var diags []Diagnostic
+	for _, op := range ops {
+		if op.Select {
+			continue
+		}
+		var locations []string
+		var related []token.Position
+		concurrent := false
+		for _, other := range counterparts {
+			if !sameGoroutine(op, other) {
+				concurrent = true
+				break
+			}
+			locations = append(locations, other.Location)
+			related = append(related, other.Position)
+		}
+		if concurrent {
+			continue
+		}
+		diags = append(diags, Diagnostic{
+			Rule:     RuleDeadlock,
+			Location: op.Location,
+			Position: op.Position,
+			pos:      op.pos,
+			Message: fmt.Sprintf("%s unbuffered channel %s blocks forever: every %s (%s) runs on the same goroutine (%s)",
+				verb, channel.Name, counterpart, strings.Join(locations, ", "), op.Actor()),
+			Related: related,
+		})
+	}
+	return diags
+}
+
+// checkLeak flags blocking operations on an unbuffered channel performed by
+// a goroutine started with go when every counterpart is a select case that
+// can give up, as the select may take its default, timeout or ctx.Done()
+// case instead and return, or when every counterpart is in the function
+// starting the goroutine, which may return without reaching any of them.
+// Either way the goroutine stays blocked forever.
+func checkLeak(channel *ChannelInfo) []Diagnostic {
+	if !unbuffered(channel) {
+		return nil
+	}
+	receives := append(append([]Operation{}, channel.ReceiveOps...), channel.RangeOps...)
+	unblocks := append(append([]Operation{}, channel.SendOps...), channel.CloseOps...)
+
+	var diags []Diagnostic
+	diags = append(diags, leakedOps(channel, "send on", channel.SendOps, receives, "receive")...)
+	diags = append(diags, leakedOps(channel, "receive from", receives, unblocks, "send or close")...)
+	return diags
+}
+
+// leakedOps reports the blocking operations in ops run by a spawned
+// goroutine whose counterparts may all be abandoned by their select or
+// skipped by the function starting the goroutine.
+func leakedOps(channel *ChannelInfo, verb string, ops, counterparts []Operation, counterpart string) []Diagnostic {
+	if len(counterparts) == 0 {
+		return nil
+	}
+	var locations []string
+	var related []token.Position
+	giveUp := true
+	for _, other := range counterparts {
+		giveUp = giveUp && other.SelectCanGiveUp
+		locations = append(locations, other.Location)
+		related = append(related, other.Position)
+	}
+	// skipped reports whether the function starting the goroutine of op
+	// may return before every counterpart, all of which it runs itself.
+	// Goroutines running several times at once are left alone, as the
+	// function may wait for some of them only.
+	skipped := func(op Operation) bool {
+		if op.spawner == "" || op.MultiInstance {
+			return false
+		}
+		for _, other := range counterparts {
+			if other.Goroutine != "" || other.Func != op.spawner || other.Select {
+				return false
+			}
+		}
+		return true
+	}
+
+	var diags []Diagnostic
+	for _, op := range ops {
+		if op.Goroutine == "" || op.Select {
+			continue
+		}
+		var message string
+		switch {
+		case giveUp:
+			message = fmt.Sprintf("goroutine started at %s may leak: %s unbuffered channel %s blocks forever when the select (%s) waiting for its %s times out or is cancelled",
+				op.Goroutine, verb, channel.Name, strings.Join(locations, ", "), counterpart)
+		case skipped(op):
+			message = fmt.Sprintf("goroutine started at %s may leak: %s unbuffered channel %s blocks forever when %s returns before its %s (%s)",
+				op.Goroutine, verb, channel.Name, op.spawner, counterpart, strings.Join(locations, ", "))
+		default:
+			continue
+		}
+		diags = append(diags, Diagnostic{
+			Rule:     RuleLeak,
+			Location: op.Location,
+			Position: op.Position,
+			pos:      op.pos,
+			Message:  message,
+			Related:  related,
+		})
+	}
+	return diags
+}
+
+// checkClose flags the uses of close that panic at run time: a close after
+// a close that may run before it, as in a loop, and a send after a close
+// that precedes it on every path.
+func checkClose(channel *ChannelInfo) []Diagnostic {
+	var diags []Diagnostic
+	for _, op := range channel.CloseOps {
+		if !op.ClosedAt.IsValid() {
+			continue
+		}
+		d := Diagnostic{
+			Rule:     RuleDoubleClose,
+			Location: op.Location,
+			Position: op.Position,
+			pos:      op.pos,
+			Message: fmt.Sprintf("channel %s is closed again after the close at %s, which panics",
+				channel.Name, lineOf(op.ClosedAt)),
+			Related: []token.Position{op.ClosedAt},
+		}
+		switch {
+		case op.MayBeClosed && op.ClosedAt == op.Position:
+			d.Message = fmt.Sprintf("channel %s may be closed again by this close, which runs in a loop, and panic",
+				channel.Name)
+			d.Related = nil
+		case op.MayBeClosed:
+			d.Message = fmt.Sprintf("channel %s may be closed again after the close at %s, which panics",
+				channel.Name, lineOf(op.ClosedAt))
+		}
+		diags = append(diags, d)
+	}
+	for _, op := range channel.SendOps {
+		if op.ClosedAt.IsValid() {
+			diags = append(diags, Diagnostic{
+				Rule:     RuleSendAfterClose,
+				Location: op.Location,
+				Position: op.Position,
+				pos:      op.pos,
+				Message: fmt.Sprintf("send on channel %s after the close at %s panics",
+					channel.Name, lineOf(op.ClosedAt)),
+				Related: []token.Position{op.ClosedAt},
+			})
+		}
+	}
+	return diags
+}
+
+// lineOf formats pos as a "file:line" location, like Operation.Location.
+func lineOf(pos token.Position) string {
+	return fmt.Sprintf("%s:%d", pos.Filename, pos.Line)
+}
+
+// checkOwnership enforces that only the sending side closes a channel and
+// that a single party owns closing it: it flags closes by parties that
+// receive from the channel without sending on it, and closes by each of
+// several parties, counting every instance of a goroutine that may run
+// several times at once.
+func checkOwnership(channel *ChannelInfo) []Diagnostic {
+	parties := make(map[string]Party)
+	var closers []string
+	several := false
+	for _, p := range channel.Parties {
+		parties[p.Name] = p
+		if !p.Closes {
+			continue
+		}
+		if p.MultiInstance {
+			closers = append(closers, p.Name+" (several instances)")
+			several = true
+		} else {
+			closers = append(closers, p.Name)
+		}
+	}
+	several = several || len(closers) > 1
+
+	var diags []Diagnostic
+	for _, op := range channel.CloseOps {
+		p := parties[op.Actor()]
+		if p.Receives && !p.Sends {
+			var related []token.Position
+			for _, send := range channel.SendOps {
+				related = append(related, send.Position)
+			}
+			diags = append(diags, Diagnostic{
+				Rule:     RuleNonOwnerClose,
+				Location: op.Location,
+				Position: op.Position,
+				pos:      op.pos,
+				Message: fmt.Sprintf("channel %s is closed by %s, which only receives from it; only a sender should close a channel",
+					channel.Name, p.Name),
+				Related: related,
+			})
+		}
+		if several {
+			var related []token.Position
+			for _, other := range channel.CloseOps {
+				if other.Actor() != p.Name {
+					related = append(related, other.Position)
+				}
+			}
+			diags = append(diags, Diagnostic{
+				Rule:     RuleMultipleClosers,
+				Location: op.Location,
+				Position: op.Position,
+				pos:      op.pos,
+				Message: fmt.Sprintf("channel %s is closed by more than one party (%s); it may be closed twice",
+					channel.Name, strings.Join(closers, ", ")),
+				Related: related,
+			})
+		}
+	}
+	return diags
+}
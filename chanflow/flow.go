@@ -0,0 +1,148 @@
+package chanflow
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// flow records that the channel held by From is stored into To, through an
+// assignment, a call argument, a return value or a struct literal field.
+// Both are objectKeys.
+type flow struct {
+	From string
+	To   string
+}
+
+// nodeFlows returns the channel flows established by n. sig is the
+// signature of the function enclosing n and resolves return statements,
+// and key the objectKey of objects. Objects are not checked against the
+// tracked channels here; resolveFlows ignores flows between untracked
+// objects.
+func nodeFlows(info *types.Info, n ast.Node, sig *types.Signature, key func(types.Object) string) []flow {
+	var flows []flow
+	add := func(from, to types.Object) {
+		if from != nil && to != nil && from != to {
+			flows = append(flows, flow{From: key(from), To: key(to)})
+		}
+	}
+
+	switch x := n.(type) {
+	case *ast.AssignStmt:
+		if len(x.Lhs) == len(x.Rhs) {
+			for i := range x.Lhs {
+				add(channelObject(info, x.Rhs[i]), channelObject(info, x.Lhs[i]))
+			}
+		} else if call, ok := x.Rhs[0].(*ast.CallExpr); ok && len(x.Rhs) == 1 {
+			// a, b := f() takes each result of f in turn.
+			if fn, ok := typeutil.Callee(info, call).(*types.Func); ok {
+				results := fn.Origin().Signature().Results()
+				for i := 0; i < len(x.Lhs) && i < results.Len(); i++ {
+					add(results.At(i), channelObject(info, x.Lhs[i]))
+				}
+			}
+		}
+	case *ast.ValueSpec:
+		for i, value := range x.Values {
+			if i < len(x.Names) {
+				add(channelObject(info, value), channelObject(info, x.Names[i]))
+			}
+		}
+	case *ast.CallExpr:
+		fn := typeutil.StaticCallee(info, x)
+		if fn == nil {
+			break
+		}
+		params := fn.Origin().Signature().Params()
+		variadic := fn.Signature().Variadic()
+		for i, arg := range x.Args {
+			if i >= params.Len() || (variadic && i >= params.Len()-1) {
+				break
+			}
+			add(channelObject(info, arg), params.At(i))
+		}
+	case *ast.ReturnStmt:
+		if sig == nil || len(x.Results) != sig.Results().Len() {
+			break
+		}
+		for i, result := range x.Results {
+			add(channelObject(info, result), sig.Results().At(i))
+		}
+	case *ast.CompositeLit:
+		st, ok := underlyingType(info, x).(*types.Struct)
+		if !ok {
+			break
+		}
+		for i, elt := range x.Elts {
+			if kv, ok := elt.(*ast.KeyValueExpr); ok {
+				add(channelObject(info, kv.Value), channelObject(info, kv.Key))
+			} else if i < st.NumFields() {
+				add(channelObject(info, elt), st.Field(i).Origin())
+			}
+		}
+	case *ast.RangeStmt:
+		// for _, ch := range chans yields the channels held by chans.
+		if x.Value == nil {
+			break
+		}
+		t := underlyingType(info, x.X)
+		if _, isChan := t.(*types.Chan); t != nil && !isChan {
+			add(channelObject(info, x.X), channelObject(info, x.Value))
+		}
+	}
+	return flows
+}
+
+// resolveFlows folds channels that only ever hold a channel made elsewhere
+// into the channels they receive it from. Starting at every channel with a
+// make site, it follows the recorded flows through parameters, results,
+// fields and locals, copies the operations found on each alias into the
+// originating channel and drops the alias from channels. Channels with a
+// make site are never folded, and an alias reached from several make sites
+// contributes its operations to each of them.
+func resolveFlows(channels map[string]*ChannelInfo, flows []flow) {
+	next := make(map[string][]string)
+	for _, f := range flows {
+		next[f.From] = append(next[f.From], f.To)
+	}
+
+	folded := make(map[string]bool)
+	for obj, origin := range channels {
+		if len(origin.MakeOps) == 0 {
+			continue
+		}
+		seen := map[string]bool{obj: true}
+		queue := append([]string(nil), next[obj]...)
+		for len(queue) > 0 {
+			cur := queue[0]
+			queue = queue[1:]
+			if seen[cur] {
+				continue
+			}
+			seen[cur] = true
+			alias, tracked := channels[cur]
+			if !tracked || len(alias.MakeOps) > 0 {
+				continue
+			}
+			origin.SendOps = append(origin.SendOps, alias.SendOps...)
+			origin.ReceiveOps = append(origin.ReceiveOps, alias.ReceiveOps...)
+			origin.CloseOps = append(origin.CloseOps, alias.CloseOps...)
+			origin.RangeOps = append(origin.RangeOps, alias.RangeOps...)
+			origin.ReturnedFrom = append(origin.ReturnedFrom, alias.ReturnedFrom...)
+			origin.PassedTo = append(origin.PassedTo, alias.PassedTo...)
+			for _, file := range alias.UsedInFiles {
+				origin.UsedInFiles = appendIfNotExists(origin.UsedInFiles, file)
+			}
+			origin.Aliases = appendIfNotExists(origin.Aliases,
+				fmt.Sprintf("%s (%s) at %s", alias.Name, alias.Kind, alias.Location))
+			folded[cur] = true
+			queue = append(queue, next[cur]...)
+		}
+	}
+
+	for obj := range folded {
+		delete(channels, obj)
+	}
+}
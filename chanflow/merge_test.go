@@ -0,0 +1,56 @@
+package chanflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"testing"
+)
+
+// TestMergeFilesKeepsFacts checks that merging the facts of files leaves
+// them as they were found, so that they can be cached and merged again
+// without changing the channels merged before.
+func TestMergeFilesKeepsFacts(t *testing.T) {
+	decl := fileDecl{Key: "p.ch", Channel: &ChannelInfo{
+		ID:          "ch@a.go:3:5",
+		Name:        "ch",
+		Kind:        KindPackageVar,
+		Location:    "a.go:3",
+		SendOps:     make([]Operation, 0, 10),
+		UsedInFiles: []string{"a.go"},
+	}}
+	send := func(file string, line int) fileOp {
+		return fileOp{Key: "p.ch", Kind: opSend, Op: Operation{
+			Location: fmt.Sprintf("%s:%d", file, line),
+			Func:     "p.f",
+			Position: token.Position{Filename: file, Line: line, Column: 2},
+		}}
+	}
+	merge := func(ops ...fileOp) *ChannelInfo {
+		t.Helper()
+		list := resolveFiles([]*fileFacts{{Path: "a.go", Decls: []fileDecl{decl}, Ops: ops}})
+		if len(list) != 1 {
+			t.Fatalf("got %d channels, want 1", len(list))
+		}
+		return list[0]
+	}
+	encode := func(channel *ChannelInfo) string {
+		t.Helper()
+		data, err := json.Marshal(channel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	facts := encode(decl.Channel)
+	first := merge(send("a.go", 5))
+	want := encode(first)
+	merge(send("a.go", 7))
+	if got := encode(first); got != want {
+		t.Errorf("channel merged first, after another merge:\n%s\nwant:\n%s", got, want)
+	}
+	if got := encode(decl.Channel); got != facts {
+		t.Errorf("facts after merging:\n%s\nwant:\n%s", got, facts)
+	}
+}
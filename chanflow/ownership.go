@@ -0,0 +1,79 @@
+package chanflow
+
+import "sort"
+
+// Party is a goroutine or function using a channel and what it does with
+// it. Operations of a function run on whichever goroutine calls it, so the
+// function stands for that goroutine. MultiInstance marks a goroutine that
+// may run several times at once, each instance a party of its own.
+type Party struct {
+	Name          string
+	Sends         bool
+	Receives      bool
+	Closes        bool
+	MultiInstance bool
+}
+
+// resolveOwnership fills in the parties of channel and its owner: the only
+// party closing it or, when nobody closes it, the only party sending on it.
+// The owner is left empty when several parties share that role, counting
+// the instances of a party that may run several times at once.
+func resolveOwnership(channel *ChannelInfo) {
+	byName := make(map[string]*Party)
+	mark := func(ops []Operation, set func(*Party)) {
+		for _, op := range ops {
+			name := op.Actor()
+			p := byName[name]
+			if p == nil {
+				p = &Party{Name: name}
+				byName[name] = p
+			}
+			p.MultiInstance = p.MultiInstance || op.MultiInstance
+			set(p)
+		}
+	}
+	mark(channel.SendOps, func(p *Party) { p.Sends = true })
+	mark(channel.ReceiveOps, func(p *Party) { p.Receives = true })
+	mark(channel.RangeOps, func(p *Party) { p.Receives = true })
+	mark(channel.CloseOps, func(p *Party) { p.Closes = true })
+
+	channel.Parties = channel.Parties[:0]
+	for _, p := range byName {
+		channel.Parties = append(channel.Parties, *p)
+	}
+	sort.Slice(channel.Parties, func(i, j int) bool {
+		return channel.Parties[i].Name < channel.Parties[j].Name
+	})
+
+	var closers, senders []Party
+	for _, p := range channel.Parties {
+		if p.Closes {
+			closers = append(closers, p)
+		}
+		if p.Sends {
+			senders = append(senders, p)
+		}
+	}
+	channel.Owner = ""
+	switch {
+	case len(closers) == 1 && !closers[0].MultiInstance:
+		channel.Owner = closers[0].Name
+	case len(closers) == 0 && len(senders) == 1 && !senders[0].MultiInstance:
+		channel.Owner = senders[0].Name
+	}
+}
+
+// Roles lists what p does with its channel.
+func (p Party) Roles() []string {
+	var roles []string
+	if p.Sends {
+		roles = append(roles, "send")
+	}
+	if p.Receives {
+		roles = append(roles, "receive")
+	}
+	if p.Closes {
+		roles = append(roles, "close")
+	}
+	return roles
+}
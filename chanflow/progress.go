@@ -0,0 +1,61 @@
+package chanflow
+
+import (
+	"sync"
+	"time"
+)
+
+// Stages of Analyze reported in Progress.Stage.
+const (
+	StageLoad    = "loading"
+	StageAnalyze = "analyzing"
+	StageDone    = "done"
+)
+
+// Progress is a snapshot of the work done by Analyze, passed to
+// Options.Progress.
+type Progress struct {
+	Stage string
+	// FilesParsed counts the files parsed while loading, dependencies
+	// included.
+	FilesParsed int
+	// Packages counts the type-checked packages once loading is done and
+	// Cached the packages whose findings were taken from Options.CacheDir
+	// instead.
+	Packages int
+	Cached   int
+	// Files is the number of files to analyze and FilesAnalyzed those
+	// analyzed so far. Both stay zero with BackendSSA, which analyzes whole
+	// programs.
+	Files         int
+	FilesAnalyzed int
+	Elapsed       time.Duration
+}
+
+// tracker serializes the updates of a Progress and passes each to report.
+// A nil tracker ignores updates.
+type tracker struct {
+	mu       sync.Mutex
+	start    time.Time
+	progress Progress
+	report   func(Progress)
+}
+
+func newTracker(report func(Progress)) *tracker {
+	if report == nil {
+		return nil
+	}
+	return &tracker{start: time.Now(), report: report}
+}
+
+// update applies f to the progress and reports the result.
+func (t *tracker) update(f func(*Progress)) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	f(&t.progress)
+	t.progress.Elapsed = time.Since(t.start)
+	t.report(t.progress)
+}
@@ -0,0 +1,444 @@
+package chanflow
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// Analysis backends accepted in Options.Backend.
+const (
+	BackendAST = "ast"
+	BackendSSA = "ssa"
+)
+
+// Options configures Analyze.
+type Options struct {
+	// Dir is the directory patterns are resolved in; empty means the
+	// current directory.
+	Dir string
+	// Backend selects how channels are found: BackendAST, the default,
+	// matches the type-checked syntax trees and BackendSSA follows values
+	// through the SSA form of the packages.
+	Backend string
+	// Tags are the build tags files are selected with, in addition to
+	// GOOS, GOARCH and the other constraints of the environment.
+	Tags []string
+	// Tests includes the _test.go files of the packages and their external
+	// test packages.
+	Tests bool
+	// Generated includes the files marked as generated with a
+	// "// Code generated ... DO NOT EDIT." comment, which are left out by
+	// default.
+	Generated bool
+	// Env is appended to the environment of the go command, as in
+	// GOOS=windows or GOFLAGS=-mod=vendor.
+	Env []string
+	// Severities overrides the severity of rules by ID; SeverityOff
+	// disables a rule.
+	Severities map[string]string
+	// Exclude lists path.Match patterns of files left out of the analysis.
+	// A pattern without a slash matches any element of a file path below
+	// Dir, as in "gen" or "*_mock.go"; one with a slash matches its leading
+	// elements, relative to Dir unless the pattern is absolute.
+	Exclude []string
+	// Workers is the number of files analyzed concurrently; zero means
+	// GOMAXPROCS.
+	Workers int
+	// Progress, if set, is called with the progress of the analysis every
+	// time it advances. Calls may come from any goroutine but never overlap.
+	Progress func(Progress)
+	// CacheDir, if set, is the directory the findings of BackendAST are
+	// kept in between runs, package by package, so that only the packages
+	// whose files or dependencies changed are type-checked and analyzed
+	// again. See DefaultCacheDir.
+	CacheDir string
+}
+
+// Report is the result of Analyze. Channels are sorted by declaration and
+// Diagnostics, which repeats the diagnostics of every channel, by location.
+type Report struct {
+	Channels      []*ChannelInfo
+	Goroutines    []Goroutine
+	Diagnostics   []Diagnostic
+	PackageErrors []PackageError
+}
+
+// Goroutine is a goroutine started by a go statement at Site, and
+// Position, with the functions it runs that use channels and the IDs of
+// those channels.
+type Goroutine struct {
+	Site     string
+	Position token.Position
+	Funcs    []string
+	Channels []string
+}
+
+// PackageError is an error loading or type-checking a package. Analysis
+// goes on with whatever the package provides.
+type PackageError struct {
+	Package string
+	Message string
+}
+
+// Analyze loads the packages matching the Go package patterns, "./..." when
+// there are none, finds their channels and runs every check over them.
+// Patterns are resolved by the go command like those of go build: import
+// paths, relative directories and ... wildcards within the module or
+// workspace containing Options.Dir. Analyze stops early with the error of
+// ctx once it is done.
+func Analyze(ctx context.Context, patterns []string, opts Options) (*Report, error) {
+	if opts.Backend == "" {
+		opts.Backend = BackendAST
+	}
+	if opts.Backend != BackendAST && opts.Backend != BackendSSA {
+		return nil, fmt.Errorf("unknown backend %q: use %s or %s", opts.Backend, BackendAST, BackendSSA)
+	}
+	if err := checkSeverities(opts.Severities); err != nil {
+		return nil, err
+	}
+	for _, pattern := range opts.Exclude {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("bad exclude pattern %q: %w", pattern, err)
+		}
+	}
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+
+	fset := token.NewFileSet()
+	cfg := &packages.Config{
+		Context: ctx,
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedSyntax |
+			packages.NeedTypes | packages.NeedTypesInfo,
+		Dir:   opts.Dir,
+		Fset:  fset,
+		Tests: opts.Tests,
+	}
+	if len(opts.Tags) > 0 {
+		cfg.BuildFlags = []string{"-tags=" + strings.Join(opts.Tags, ",")}
+	}
+	if len(opts.Env) > 0 {
+		cfg.Env = append(os.Environ(), opts.Env...)
+	}
+	if opts.Backend == BackendSSA {
+		// SSA construction needs the types of every dependency.
+		cfg.Mode |= packages.NeedImports | packages.NeedDeps | packages.NeedTypesSizes
+	}
+	t := newTracker(opts.Progress)
+	if t != nil {
+		t.update(func(p *Progress) { p.Stage = StageLoad })
+		cfg.ParseFile = func(fset *token.FileSet, filename string, src []byte) (*ast.File, error) {
+			t.update(func(p *Progress) { p.FilesParsed++ })
+			// The mode of the default parser of go/packages.
+			return parser.ParseFile(fset, filename, src, parser.AllErrors|parser.ParseComments)
+		}
+	}
+
+	var channels []*ChannelInfo
+	var errs []PackageError
+	var err error
+	if opts.Backend == BackendAST && opts.CacheDir != "" {
+		channels, errs, err = analyzeCached(cfg, patterns, opts, t)
+	} else {
+		var pkgs []*packages.Package
+		pkgs, err = loadPackages(cfg, patterns, opts, t)
+		if err != nil {
+			return nil, err
+		}
+		if opts.Backend == BackendSSA {
+			var skip func(string) bool
+			if skip, err = skippedFiles(fset, pkgs, opts); err != nil {
+				return nil, err
+			}
+			channels, err = analyzeSSA(ctx, fset, pkgs, skip)
+		} else {
+			channels, err = analyzeSyntax(ctx, fset, pkgs, opts.Workers, t)
+		}
+		errs = packageErrors(pkgs)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	applySeverities(channels, opts.Severities)
+	report := newReport(channels)
+	report.PackageErrors = errs
+	t.update(func(p *Progress) { p.Stage = StageDone })
+	return report, nil
+}
+
+// loadPackages loads the packages matching patterns with cfg and leaves out
+// the files excluded by opts.
+func loadPackages(cfg *packages.Config, patterns []string, opts Options, t *tracker) ([]*packages.Package, error) {
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return nil, fmt.Errorf("loading packages: %w", err)
+	}
+	if err := cfg.Context.Err(); err != nil {
+		return nil, err
+	}
+	if opts.Tests {
+		pkgs = testVariants(pkgs)
+	}
+	// The SSA program is built from every file of a package, so the SSA
+	// backend drops its findings in skipped files afterwards instead.
+	if opts.Backend != BackendSSA {
+		skip, err := skippedFiles(cfg.Fset, pkgs, opts)
+		if err != nil {
+			return nil, err
+		}
+		excludeFiles(cfg.Fset, pkgs, skip)
+	}
+
+	if t != nil {
+		checked := 0
+		packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+			if pkg.TypesInfo != nil {
+				checked++
+			}
+		})
+		t.update(func(p *Progress) {
+			p.Stage = StageAnalyze
+			p.Packages += checked
+		})
+	}
+	return pkgs, nil
+}
+
+// packageErrors returns the errors of pkgs. The errors go list reports
+// for a package with type errors repeat them, as it compiles the package
+// for its export data, so they are left out.
+func packageErrors(pkgs []*packages.Package) []PackageError {
+	var errs []PackageError
+	for _, pkg := range pkgs {
+		typeErrors := slices.ContainsFunc(pkg.Errors, func(e packages.Error) bool {
+			return e.Kind == packages.TypeError
+		})
+		for _, e := range pkg.Errors {
+			if typeErrors && e.Kind == packages.ListError {
+				continue
+			}
+			errs = append(errs, PackageError{Package: pkg.PkgPath, Message: e.Error()})
+		}
+	}
+	return errs
+}
+
+// testVariants drops the packages whose files are repeated in their test
+// variant, "p [p.test]", and the generated test mains, so that every file
+// is analyzed once.
+func testVariants(pkgs []*packages.Package) []*packages.Package {
+	tested := make(map[string]bool)
+	for _, pkg := range pkgs {
+		if pkg.ID != pkg.PkgPath && strings.HasPrefix(pkg.ID, pkg.PkgPath+" [") {
+			tested[pkg.PkgPath] = true
+		}
+	}
+	var out []*packages.Package
+	for _, pkg := range pkgs {
+		if pkg.ID == pkg.PkgPath && tested[pkg.PkgPath] || strings.HasSuffix(pkg.ID, ".test") {
+			continue
+		}
+		out = append(out, pkg)
+	}
+	return out
+}
+
+// skippedFiles returns a function reporting whether a file of pkgs is left
+// out of the analysis: matched by opts.Exclude or, unless opts.Generated,
+// generated. It returns nil when no file is.
+func skippedFiles(fset *token.FileSet, pkgs []*packages.Package, opts Options) (func(name string) bool, error) {
+	generated := make(map[string]bool)
+	if !opts.Generated {
+		for _, pkg := range pkgs {
+			for _, file := range pkg.Syntax {
+				if ast.IsGenerated(file) {
+					generated[fset.File(file.Pos()).Name()] = true
+				}
+			}
+		}
+	}
+	if len(opts.Exclude) == 0 && len(generated) == 0 {
+		return nil, nil
+	}
+	root, err := filepath.Abs(opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+	return func(name string) bool {
+		return generated[name] || excluded(root, name, opts.Exclude)
+	}, nil
+}
+
+// excludeFiles drops the syntax of the files matched by skip from pkgs.
+func excludeFiles(fset *token.FileSet, pkgs []*packages.Package, skip func(string) bool) {
+	if skip == nil {
+		return
+	}
+	for _, pkg := range pkgs {
+		files := pkg.Syntax[:0]
+		for _, file := range pkg.Syntax {
+			if !skip(fset.File(file.Pos()).Name()) {
+				files = append(files, file)
+			}
+		}
+		pkg.Syntax = files
+	}
+}
+
+// dropExcluded removes the channels declared in the files matched by skip
+// and the operations of the other channels in those files.
+func dropExcluded(channels []*ChannelInfo, skip func(string) bool) []*ChannelInfo {
+	if skip == nil {
+		return channels
+	}
+	inSkipped := func(op Operation) bool {
+		return skip(op.Position.Filename)
+	}
+	kept := channels[:0]
+	for _, channel := range channels {
+		if skip(channel.Position.Filename) {
+			continue
+		}
+		for _, ops := range []*[]Operation{
+			&channel.MakeOps, &channel.SendOps, &channel.ReceiveOps, &channel.CloseOps,
+			&channel.RangeOps, &channel.ReturnedFrom, &channel.PassedTo,
+		} {
+			*ops = slices.DeleteFunc(*ops, inSkipped)
+		}
+		channel.UsedInFiles = slices.DeleteFunc(channel.UsedInFiles, skip)
+		kept = append(kept, channel)
+	}
+	return kept
+}
+
+// excluded reports whether the file name matches one of patterns, as
+// described in Options.Exclude.
+func excluded(root, name string, patterns []string) bool {
+	abs := strings.Split(filepath.ToSlash(name), "/")
+	var rel []string
+	if r, err := filepath.Rel(root, name); err == nil {
+		rel = strings.Split(filepath.ToSlash(r), "/")
+	}
+	// The directories above root do not count, but files outside it have
+	// no other elements to match.
+	elems := abs
+	if rel != nil && rel[0] != ".." {
+		elems = rel
+	}
+	for _, pattern := range patterns {
+		pattern = filepath.ToSlash(pattern)
+		switch {
+		case !strings.Contains(pattern, "/"):
+			for _, elem := range elems {
+				if ok, _ := path.Match(pattern, elem); ok {
+					return true
+				}
+			}
+		case path.IsAbs(pattern) || filepath.IsAbs(pattern):
+			if matchPrefix(pattern, abs) {
+				return true
+			}
+		case rel != nil:
+			if matchPrefix(pattern, rel) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchPrefix reports whether the elements of the slash-separated pattern
+// match the leading elements of elems.
+func matchPrefix(pattern string, elems []string) bool {
+	parts := strings.Split(strings.TrimSuffix(pattern, "/"), "/")
+	if len(parts) > len(elems) {
+		return false
+	}
+	for i, part := range parts {
+		if ok, _ := path.Match(part, elems[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// newReport orders channels and gathers their goroutines and diagnostics.
+func newReport(channels []*ChannelInfo) *Report {
+	report := &Report{Channels: channels}
+	sort.Slice(report.Channels, func(i, j int) bool {
+		a, b := report.Channels[i], report.Channels[j]
+		if a.Location != b.Location {
+			return lessLocation(a.Location, b.Location)
+		}
+		return a.ID < b.ID
+	})
+
+	goroutines := make(map[string]*Goroutine)
+	for _, channel := range report.Channels {
+		for _, ops := range [][]Operation{
+			channel.SendOps, channel.ReceiveOps, channel.CloseOps,
+			channel.RangeOps, channel.ReturnedFrom, channel.PassedTo,
+		} {
+			for _, op := range ops {
+				if op.Goroutine == "" {
+					continue
+				}
+				g := goroutines[op.Goroutine]
+				if g == nil {
+					g = &Goroutine{Site: op.Goroutine, Position: op.GoroutinePosition}
+					goroutines[op.Goroutine] = g
+				}
+				g.Funcs = appendIfNotExists(g.Funcs, op.Func)
+				g.Channels = appendIfNotExists(g.Channels, channel.ID)
+			}
+		}
+		report.Diagnostics = append(report.Diagnostics, channel.Diagnostics...)
+	}
+	for _, g := range goroutines {
+		sort.Strings(g.Funcs)
+		report.Goroutines = append(report.Goroutines, *g)
+	}
+	sort.Slice(report.Goroutines, func(i, j int) bool {
+		return lessLocation(report.Goroutines[i].Site, report.Goroutines[j].Site)
+	})
+	sort.SliceStable(report.Diagnostics, func(i, j int) bool {
+		return lessLocation(report.Diagnostics[i].Location, report.Diagnostics[j].Location)
+	})
+	return report
+}
+
+// lessPosition orders positions by file, line and column.
+func lessPosition(a, b token.Position) bool {
+	if a.Filename != b.Filename {
+		return a.Filename < b.Filename
+	}
+	if a.Line != b.Line {
+		return a.Line < b.Line
+	}
+	return a.Column < b.Column
+}
+
+// lessLocation orders "file:line" locations by file, then numerically by
+// line.
+func lessLocation(a, b string) bool {
+	i, j := strings.LastIndex(a, ":"), strings.LastIndex(b, ":")
+	if i < 0 || j < 0 || a[:i] != b[:j] {
+		return a < b
+	}
+	la, _ := strconv.Atoi(a[i+1:])
+	lb, _ := strconv.Atoi(b[j+1:])
+	return la < lb
+}
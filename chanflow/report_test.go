@@ -0,0 +1,61 @@
+package chanflow
+
+import "testing"
+
+func TestExcluded(t *testing.T) {
+	for _, tt := range []struct {
+		root     string
+		name     string
+		patterns []string
+		want     bool
+	}{
+		// Patterns without a slash match any element of the path.
+		{"/project", "/project/gen/a.go", []string{"gen"}, true},
+		{"/project", "/project/x/gen/a.go", []string{"gen"}, true},
+		{"/project", "/project/generated/a.go", []string{"gen"}, false},
+		{"/gen/project", "/gen/project/a.go", []string{"gen"}, false},
+		{"/gen/project", "/gen/project/gen/a.go", []string{"gen"}, true},
+		{"/project", "/elsewhere/gen/a.go", []string{"gen"}, true},
+		{"/project", "/project/a_mock.go", []string{"*_mock.go"}, true},
+		{"/project", "/project/x/a_mock.go", []string{"*_mock.go"}, true},
+		{"/project", "/project/a.go", []string{"*_mock.go"}, false},
+		// Patterns with a slash match the leading elements relative to root.
+		{"/project", "/project/internal/gen/a.go", []string{"internal/gen"}, true},
+		{"/project", "/project/internal/gen/a.go", []string{"internal/gen/"}, true},
+		{"/project", "/project/internal/gen/x/a.go", []string{"internal/gen"}, true},
+		{"/project", "/project/x/internal/gen/a.go", []string{"internal/gen"}, false},
+		{"/project", "/project/api/a.pb.go", []string{"api/*.pb.go"}, true},
+		{"/project", "/project/api/a.go", []string{"api/*.pb.go"}, false},
+		{"/project", "/elsewhere/internal/gen/a.go", []string{"internal/gen"}, false},
+		// Absolute patterns match the leading elements of the path.
+		{"/project", "/project/internal/gen/a.go", []string{"/project/internal"}, true},
+		{"/project", "/elsewhere/gen/a.go", []string{"/elsewhere/gen"}, true},
+		{"/project", "/project/gen/a.go", []string{"/elsewhere/gen"}, false},
+		// Any pattern may match.
+		{"/project", "/project/a_mock.go", []string{"gen", "*_mock.go"}, true},
+		{"/project", "/project/a.go", nil, false},
+	} {
+		if got := excluded(tt.root, tt.name, tt.patterns); got != tt.want {
+			t.Errorf("excluded(%q, %q, %q) = %t, want %t", tt.root, tt.name, tt.patterns, got, tt.want)
+		}
+	}
+}
+
+func TestMatchPrefix(t *testing.T) {
+	for _, tt := range []struct {
+		pattern string
+		elems   []string
+		want    bool
+	}{
+		{"a/b", []string{"a", "b", "c.go"}, true},
+		{"a/b/", []string{"a", "b", "c.go"}, true},
+		{"a/*", []string{"a", "b", "c.go"}, true},
+		{"a/b/c.go/d", []string{"a", "b", "c.go"}, false},
+		{"b", []string{"a", "b", "c.go"}, false},
+		{"a/c", []string{"a", "b", "c.go"}, false},
+	} {
+		if got := matchPrefix(tt.pattern, tt.elems); got != tt.want {
+			t.Errorf("matchPrefix(%q, %q) = %t, want %t", tt.pattern, tt.elems, got, tt.want)
+		}
+	}
+}
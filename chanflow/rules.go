@@ -0,0 +1,120 @@
+package chanflow
+
+import (
+	"fmt"
+)
+
+// Diagnostic rules reported in Diagnostic.Rule.
+const (
+	RuleDangling    = "dangling"
+	RuleSendOnly    = "send-only"
+	RuleReceiveOnly = "receive-only"
+
+	RuleDeadlock = "deadlock"
+	RuleLeak     = "goroutine-leak"
+
+	RuleDoubleClose     = "double-close"
+	RuleSendAfterClose  = "send-after-close"
+	RuleNonOwnerClose   = "non-owner-close"
+	RuleMultipleClosers = "multiple-closers"
+)
+
+// Rule severities, named after the SARIF result levels. SeverityOff
+// disables a rule.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityNote    = "note"
+	SeverityOff     = "off"
+)
+
+// Rule describes a check: the ID reported in Diagnostic.Rule, a short
+// PascalCase name, what it looks for and the severity of its diagnostics.
+type Rule struct {
+	ID          string
+	Name        string
+	Description string
+	Severity    string
+}
+
+// Rules lists every rule.
+var Rules = []Rule{
+	{RuleDangling, "DanglingChannel",
+		"A channel with no send, receive, close or range operation.", SeverityNote},
+	{RuleSendOnly, "SendOnlyChannel",
+		"A channel that is sent on or closed but never received from.", SeverityWarning},
+	{RuleReceiveOnly, "ReceiveOnlyChannel",
+		"A channel that is received from but never sent on or closed.", SeverityWarning},
+	{RuleDeadlock, "UnbufferedDeadlock",
+		"An operation on an unbuffered channel whose every counterpart runs on the same goroutine, so it blocks forever.", SeverityError},
+	{RuleLeak, "GoroutineLeak",
+		"A goroutine blocked on an unbuffered channel whose only counterpart is a select that can time out or be cancelled, or that the function starting it may return before reaching.", SeverityWarning},
+	{RuleDoubleClose, "DoubleClose",
+		"A close of a channel that may already be closed: after a close on every or some path, in a loop, or deferred after another close; it panics.", SeverityError},
+	{RuleSendAfterClose, "SendAfterClose",
+		"A send on a channel that was already closed on every path, which panics.", SeverityError},
+	{RuleNonOwnerClose, "NonOwnerClose",
+		"A channel closed by a party that only receives from it; only the sending side should close a channel.", SeverityWarning},
+	{RuleMultipleClosers, "MultipleClosers",
+		"A channel closed by more than one goroutine or function, so that it may be closed twice.", SeverityWarning},
+}
+
+// SeverityRank orders severities from SeverityOff, 0, to SeverityError, 3.
+// It returns -1 for anything else.
+func SeverityRank(severity string) int {
+	switch severity {
+	case SeverityOff:
+		return 0
+	case SeverityNote:
+		return 1
+	case SeverityWarning:
+		return 2
+	case SeverityError:
+		return 3
+	}
+	return -1
+}
+
+// applySeverities sets the severity of every diagnostic of channels to the
+// one overrides gives its rule, or else to the rule default, and drops the
+// diagnostics of rules turned off.
+func applySeverities(channels []*ChannelInfo, overrides map[string]string) {
+	for _, channel := range channels {
+		kept := channel.Diagnostics[:0]
+		for _, d := range channel.Diagnostics {
+			rule, _ := LookupRule(d.Rule)
+			d.Severity = rule.Severity
+			if severity, ok := overrides[d.Rule]; ok {
+				d.Severity = severity
+			}
+			if d.Severity != SeverityOff {
+				kept = append(kept, d)
+			}
+		}
+		channel.Diagnostics = kept
+	}
+}
+
+// checkSeverities reports the first override naming an unknown rule or
+// severity.
+func checkSeverities(overrides map[string]string) error {
+	for id, severity := range overrides {
+		if _, ok := LookupRule(id); !ok {
+			return fmt.Errorf("unknown rule %q", id)
+		}
+		if SeverityRank(severity) < 0 {
+			return fmt.Errorf("unknown severity %q for rule %s: use error, warning, note or off", severity, id)
+		}
+	}
+	return nil
+}
+
+// LookupRule returns the rule with the given ID.
+func LookupRule(id string) (Rule, bool) {
+	for _, rule := range Rules {
+		if rule.ID == id {
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
@@ -0,0 +1,972 @@
+package chanflow
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"slices"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/callgraph/cha"
+	"golang.org/x/tools/go/callgraph/vta"
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+// ssaLoc is an abstract memory location a channel can be stored into: a
+// struct field (shared by every value of the struct type), a global or
+// local variable, or the elements of a slice, array or map.
+type ssaLoc struct {
+	field types.Object
+	value ssa.Value
+	elem  bool
+}
+
+// ssaAnalysis follows channels through the SSA form of the analyzed
+// packages. Every make(chan) is an origin whose value is traced through
+// phi nodes, conversions, closures, stores and loads, call arguments and
+// return values; the operations reached are recorded on the ChannelInfo of
+// the origin.
+type ssaAnalysis struct {
+	fset     *token.FileSet
+	cg       *callgraph.Graph
+	channels map[types.Object]*ChannelInfo
+	// initial holds the packages analyzed, whose functions channels are
+	// followed into.
+	initial map[*types.Package]bool
+
+	// loads lists the values read from each location, bindings the value
+	// bound to each closure free variable and spawns the go statements
+	// starting each function, of which looped marks those started in a
+	// loop, and goStmts the go instructions themselves. shared marks the
+	// functions called, directly or not, from a goroutine, and comms holds
+	// the sends and receives by position.
+	loads    map[ssaLoc][]ssa.Value
+	bindings map[*ssa.FreeVar]ssa.Value
+	spawns   map[*ssa.Function][]token.Position
+	looped   map[*ssa.Function]bool
+	goStmts  map[*ssa.Function][]*ssa.Go
+	shared   map[*ssa.Function]bool
+	comms    map[token.Pos]ssa.Instruction
+
+	// rangeFors holds the positions of range statements, which the SSA
+	// builder lowers to plain receives, and makeCalls the make calls by
+	// the position of their opening parenthesis. starts maps the positions
+	// the builder gives sends and calls, of their arrow, opening parenthesis
+	// or go or defer keyword, to those of the statement or call, which
+	// analyzeFile reports.
+	rangeFors map[token.Pos]bool
+	makeCalls map[token.Pos]makeCall
+	starts    map[token.Pos]token.Pos
+
+	// visited records the values already traced into each channel.
+	visited map[*ChannelInfo]map[ssa.Value]bool
+}
+
+// makeCall is a make(chan T, n) call and the type information of the
+// package containing it.
+type makeCall struct {
+	info *types.Info
+	call *ast.CallExpr
+}
+
+// analyzeSSA builds the SSA program for pkgs, which must have been loaded
+// with syntax and type information for all dependencies, and returns the
+// channels found in it after running every check over them. Dynamic calls
+// are resolved with a VTA call graph. Channels and operations in the files
+// matched by skip, if set, are dropped before the checks. It gives up
+// between functions once ctx is done.
+func analyzeSSA(ctx context.Context, fset *token.FileSet, pkgs []*packages.Package, skip func(string) bool) ([]*ChannelInfo, error) {
+	prog, _ := ssautil.Packages(pkgs, ssa.GlobalDebug)
+	prog.Build()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	initial := make(map[*types.Package]bool)
+	for _, pkg := range pkgs {
+		initial[pkg.Types] = true
+	}
+
+	var funcs []*ssa.Function
+	all := ssautil.AllFunctions(prog)
+	for fn := range all {
+		if fn.Blocks != nil && fn.Pkg != nil && initial[fn.Pkg.Pkg] {
+			funcs = append(funcs, fn)
+		}
+	}
+	// Files are parsed concurrently, so the order of their Pos ranges
+	// varies from run to run; order functions by file name and offset.
+	sort.Slice(funcs, func(i, j int) bool {
+		pi, pj := fset.Position(funcs[i].Pos()), fset.Position(funcs[j].Pos())
+		if pi.Filename != pj.Filename {
+			return pi.Filename < pj.Filename
+		}
+		return pi.Offset < pj.Offset ||
+			pi.Offset == pj.Offset && funcs[i].String() < funcs[j].String()
+	})
+
+	a := &ssaAnalysis{
+		fset:      fset,
+		cg:        vta.CallGraph(all, cha.CallGraph(prog)),
+		channels:  make(map[types.Object]*ChannelInfo),
+		initial:   initial,
+		loads:     make(map[ssaLoc][]ssa.Value),
+		bindings:  make(map[*ssa.FreeVar]ssa.Value),
+		spawns:    make(map[*ssa.Function][]token.Position),
+		looped:    make(map[*ssa.Function]bool),
+		goStmts:   make(map[*ssa.Function][]*ssa.Go),
+		shared:    make(map[*ssa.Function]bool),
+		comms:     make(map[token.Pos]ssa.Instruction),
+		rangeFors: make(map[token.Pos]bool),
+		makeCalls: make(map[token.Pos]makeCall),
+		starts:    make(map[token.Pos]token.Pos),
+		visited:   make(map[*ChannelInfo]map[ssa.Value]bool),
+	}
+	a.indexSyntax(pkgs)
+	// Free variables must be bound before loads through them are indexed.
+	for _, fn := range funcs {
+		a.indexBindings(fn)
+	}
+	for _, fn := range funcs {
+		a.indexFunc(fn)
+	}
+	for _, sites := range a.spawns {
+		sort.Slice(sites, func(i, j int) bool { return lessPosition(sites[i], sites[j]) })
+	}
+	a.indexShared()
+
+	for _, fn := range funcs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				if mc, ok := instr.(*ssa.MakeChan); ok {
+					a.traceMake(fn, mc)
+				}
+			}
+		}
+	}
+
+	// Channel parameters of functions nobody calls in the analyzed code,
+	// such as exported API, are origins of their own.
+	for _, fn := range funcs {
+		if fn.Parent() != nil {
+			continue
+		}
+		if node := a.cg.Nodes[fn]; node != nil && len(node.In) > 0 {
+			continue
+		}
+		for _, param := range fn.Params {
+			obj, ok := param.Object().(*types.Var)
+			if !ok || !holdsChannel(param.Type()) {
+				continue
+			}
+			channel := a.channel(obj, obj.Name(), obj.Pos())
+			a.trace(obj.Origin(), channel, param)
+		}
+	}
+
+	channels := make([]*ChannelInfo, 0, len(a.channels))
+	for _, channel := range a.channels {
+		channels = append(channels, channel)
+	}
+	for _, channel := range channels {
+		receives := append(append([]Operation{}, channel.ReceiveOps...), channel.RangeOps...)
+		unblocks := append(append([]Operation{}, channel.SendOps...), channel.CloseOps...)
+		a.markSpawners(channel.SendOps, receives)
+		a.markSpawners(channel.ReceiveOps, unblocks)
+		a.markSpawners(channel.RangeOps, unblocks)
+	}
+	channels = dropExcluded(channels, skip)
+	runChecks(channels)
+	return channels, nil
+}
+
+// indexSyntax records the range statements, make calls and where the sends
+// and calls of pkgs start.
+func (a *ssaAnalysis) indexSyntax(pkgs []*packages.Package) {
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Syntax {
+			ast.Inspect(file, func(n ast.Node) bool {
+				switch x := n.(type) {
+				case *ast.RangeStmt:
+					a.rangeFors[x.For] = true
+				case *ast.SendStmt:
+					a.starts[x.Arrow] = x.Pos()
+				case *ast.DeferStmt:
+					a.starts[x.Defer] = x.Call.Pos()
+				case *ast.GoStmt:
+					a.starts[x.Go] = x.Call.Pos()
+				case *ast.CallExpr:
+					a.starts[x.Lparen] = x.Pos()
+					if fun, ok := ast.Unparen(x.Fun).(*ast.Ident); ok && fun.Name == "make" {
+						a.makeCalls[x.Lparen] = makeCall{info: pkg.TypesInfo, call: x}
+					}
+				}
+				return true
+			})
+		}
+	}
+}
+
+// indexBindings records the values bound to the free variables of the
+// closures created in fn.
+func (a *ssaAnalysis) indexBindings(fn *ssa.Function) {
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			if x, ok := instr.(*ssa.MakeClosure); ok {
+				closure := x.Fn.(*ssa.Function)
+				for i, binding := range x.Bindings {
+					if i < len(closure.FreeVars) {
+						a.bindings[closure.FreeVars[i]] = binding
+					}
+				}
+			}
+		}
+	}
+}
+
+// indexFunc records the loads, sends, receives and go statements of fn.
+func (a *ssaAnalysis) indexFunc(fn *ssa.Function) {
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			switch x := instr.(type) {
+			case *ssa.UnOp:
+				if x.Op == token.MUL {
+					if loc, ok := a.locOf(x.X); ok {
+						a.loads[loc] = append(a.loads[loc], x)
+					}
+				}
+				if x.Op == token.ARROW {
+					a.comms[x.Pos()] = x
+				}
+			case *ssa.Send:
+				a.comms[a.start(x.Pos())] = x
+			case *ssa.Field:
+				if field := structField(x.X.Type(), x.Field); field != nil {
+					loc := ssaLoc{field: field}
+					a.loads[loc] = append(a.loads[loc], x)
+				}
+			case *ssa.Index:
+				loc := a.elemLoc(x.X)
+				a.loads[loc] = append(a.loads[loc], x)
+			case *ssa.Lookup:
+				loc := a.elemLoc(x.X)
+				a.loads[loc] = append(a.loads[loc], x)
+			case *ssa.Go:
+				site := a.fset.Position(x.Pos())
+				loop := inCycle(b)
+				for _, callee := range a.callees(x) {
+					a.spawns[callee] = append(a.spawns[callee], site)
+					a.looped[callee] = a.looped[callee] || loop
+					a.goStmts[callee] = append(a.goStmts[callee], x)
+				}
+			}
+		}
+	}
+}
+
+// indexShared marks the functions reachable in the call graph from the
+// functions started with go, which run on every goroutine calling them.
+func (a *ssaAnalysis) indexShared() {
+	var queue []*ssa.Function
+	for fn := range a.spawns {
+		queue = append(queue, fn)
+	}
+	for len(queue) > 0 {
+		fn := queue[0]
+		queue = queue[1:]
+		if a.shared[fn] {
+			continue
+		}
+		a.shared[fn] = true
+		if node := a.cg.Nodes[fn]; node != nil {
+			for _, edge := range node.Out {
+				queue = append(queue, edge.Callee.Func)
+			}
+		}
+	}
+}
+
+// structField returns the field at index of the struct type t points to
+// or is.
+func structField(t types.Type, index int) types.Object {
+	if ptr, ok := t.Underlying().(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	st, ok := t.Underlying().(*types.Struct)
+	if !ok || index >= st.NumFields() {
+		return nil
+	}
+	return st.Field(index).Origin()
+}
+
+// locOf returns the location addr points to.
+func (a *ssaAnalysis) locOf(addr ssa.Value) (ssaLoc, bool) {
+	switch x := addr.(type) {
+	case *ssa.FieldAddr:
+		if field := structField(x.X.Type(), x.Field); field != nil {
+			return ssaLoc{field: field}, true
+		}
+	case *ssa.Global, *ssa.Alloc:
+		return ssaLoc{value: x}, true
+	case *ssa.IndexAddr:
+		return a.elemLoc(x.X), true
+	case *ssa.FreeVar:
+		if binding, ok := a.bindings[x]; ok {
+			return a.locOf(binding)
+		}
+		return ssaLoc{value: x}, true
+	}
+	return ssaLoc{}, false
+}
+
+// elemLoc returns the location of the elements of container, a slice,
+// array, map or pointer to array.
+func (a *ssaAnalysis) elemLoc(container ssa.Value) ssaLoc {
+	loc := ssaLoc{value: container}
+	if load, ok := container.(*ssa.UnOp); ok && load.Op == token.MUL {
+		if l, ok := a.locOf(load.X); ok {
+			loc = l
+		}
+	} else if l, ok := a.locOf(container); ok {
+		loc = l
+	}
+	loc.elem = true
+	return loc
+}
+
+// callees returns the functions a call instruction may invoke.
+func (a *ssaAnalysis) callees(site ssa.CallInstruction) []*ssa.Function {
+	if callee := site.Common().StaticCallee(); callee != nil {
+		return []*ssa.Function{callee}
+	}
+	node := a.cg.Nodes[site.Parent()]
+	if node == nil {
+		return nil
+	}
+	var fns []*ssa.Function
+	for _, edge := range node.Out {
+		if edge.Site == site {
+			fns = append(fns, edge.Callee.Func)
+		}
+	}
+	return fns
+}
+
+// channel returns the ChannelInfo tracked for obj, creating it if needed.
+func (a *ssaAnalysis) channel(obj *types.Var, name string, pos token.Pos) *ChannelInfo {
+	if channel, ok := a.channels[obj.Origin()]; ok {
+		return channel
+	}
+	channel := newChannel(a.fset, obj, name, pos)
+	a.channels[obj.Origin()] = channel
+	return channel
+}
+
+// traceMake creates or extends the channel made by mc and traces it.
+func (a *ssaAnalysis) traceMake(fn *ssa.Function, mc *ssa.MakeChan) {
+	obj, name, pos := a.makeTarget(fn, mc)
+	if obj == nil {
+		// Not stored anywhere we can name; track the make site itself.
+		name = fmt.Sprintf("make(%s)", types.TypeString(mc.Type(), types.RelativeTo(fn.Pkg.Pkg)))
+		pos = a.start(mc.Pos())
+		obj = types.NewVar(pos, fn.Pkg.Pkg, name, mc.Type())
+		obj.SetKind(types.LocalVar)
+	}
+	channel := a.channel(obj, name, pos)
+	channel.MakeOps = append(channel.MakeOps, a.op(fn, mc.Pos()))
+	if mk, ok := a.makeCalls[mc.Pos()]; ok {
+		channel.Capacities = appendIfNotExists(channel.Capacities, makeCapacity(mk.info, mk.call))
+	}
+	a.trace(obj.Origin(), channel, mc)
+}
+
+// makeTarget names the variable, field, global or function result the
+// channel made by mc is first stored into, and returns where it is declared:
+// unnamed results at the name of their function.
+func (a *ssaAnalysis) makeTarget(fn *ssa.Function, mc *ssa.MakeChan) (*types.Var, string, token.Pos) {
+	for _, ref := range *mc.Referrers() {
+		switch x := ref.(type) {
+		case *ssa.DebugRef:
+			if obj, ok := x.Object().(*types.Var); ok {
+				return obj, obj.Name(), obj.Pos()
+			}
+		case *ssa.Store:
+			switch addr := x.Addr.(type) {
+			case *ssa.FieldAddr:
+				if field, ok := structField(addr.X.Type(), addr.Field).(*types.Var); ok {
+					return field, field.Name(), field.Pos()
+				}
+			case *ssa.Global:
+				if obj, ok := addr.Object().(*types.Var); ok {
+					return obj, obj.Name(), obj.Pos()
+				}
+			}
+		case *ssa.Return:
+			results := fn.Signature.Results()
+			for i, v := range x.Results {
+				if v == mc && i < results.Len() {
+					result := results.At(i)
+					if result.Name() != "" {
+						return result, result.Name(), result.Pos()
+					}
+					if fn.Object() != nil {
+						return result, fn.Object().Name() + "()", fn.Object().Pos()
+					}
+				}
+			}
+		}
+	}
+	return nil, "", token.NoPos
+}
+
+// start returns the position of the send statement or call the SSA builder
+// gives the position pos, or pos itself.
+func (a *ssaAnalysis) start(pos token.Pos) token.Pos {
+	if start, ok := a.starts[pos]; ok {
+		return start
+	}
+	return pos
+}
+
+// op describes an operation at pos in fn.
+func (a *ssaAnalysis) op(fn *ssa.Function, pos token.Pos) Operation {
+	if !pos.IsValid() {
+		pos = fn.Pos()
+	}
+	pos = a.start(pos)
+	position := a.fset.Position(pos)
+	op := Operation{
+		Location: fmt.Sprintf("%s:%d", position.Filename, position.Line),
+		Func:     ssaFuncName(fn),
+		Position: position,
+		pos:      pos,
+	}
+	if site, multi := a.goroutineOf(fn); site.IsValid() {
+		op.Goroutine = lineOf(site)
+		op.GoroutinePosition = site
+		op.MultiInstance = multi
+	} else {
+		op.shared = a.shared[fn]
+	}
+	return op
+}
+
+// goroutineOf returns the spawn site of the goroutine fn runs on, or the
+// zero Position when it runs on its caller's goroutine, and whether several
+// instances of it may run at once. Function literals inherit the goroutine
+// of the function enclosing them.
+func (a *ssaAnalysis) goroutineOf(fn *ssa.Function) (site token.Position, multi bool) {
+	for ; fn != nil; fn = fn.Parent() {
+		if sites := a.spawns[fn]; len(sites) > 0 {
+			_, parentMulti := a.goroutineOf(fn.Parent())
+			return sites[0], len(sites) > 1 || a.looped[fn] || parentMulti
+		}
+	}
+	return token.Position{}, false
+}
+
+// markSpawners sets the spawner of the operations in ops run by a goroutine
+// started from a single go statement when the function running it runs
+// every operation in counterparts, none in a loop, and may return without
+// reaching any.
+func (a *ssaAnalysis) markSpawners(ops, counterparts []Operation) {
+	for i := range ops {
+		op := &ops[i]
+		instr := a.comms[op.pos]
+		if op.Goroutine == "" || op.Select || instr == nil {
+			continue
+		}
+		var spawn *ssa.Go
+		for fn := instr.Parent(); fn != nil && spawn == nil; fn = fn.Parent() {
+			if stmts := a.goStmts[fn]; len(stmts) == 1 {
+				spawn = stmts[0]
+			}
+		}
+		if spawn == nil {
+			continue
+		}
+		name := ssaFuncName(spawn.Parent())
+		syntax := spawn.Parent().Syntax()
+		var blocking []ssa.Instruction
+		for _, other := range counterparts {
+			comm := a.comms[other.pos]
+			if other.Goroutine != "" || other.Func != name || other.Select || comm == nil || comm.Parent() != spawn.Parent() ||
+				syntax == nil || loopedAt(syntax, other.pos) {
+				blocking = nil
+				break
+			}
+			blocking = append(blocking, comm)
+		}
+		if len(blocking) > 0 && exitsAvoiding(spawn, blocking) {
+			op.spawner = name
+		}
+	}
+}
+
+// exitsAvoiding reports whether control can flow from the instruction
+// from to a return of its function without passing one of the instructions
+// in avoid. Paths ending in a panic do not return.
+func exitsAvoiding(from ssa.Instruction, avoid []ssa.Instruction) bool {
+	// scan walks instrs until one in avoid, and reports whether it went
+	// past the end of them.
+	scan := func(instrs []ssa.Instruction) bool {
+		for _, instr := range instrs {
+			if slices.Contains(avoid, instr) {
+				return false
+			}
+		}
+		return true
+	}
+	returns := func(b *ssa.BasicBlock) bool {
+		_, ok := b.Instrs[len(b.Instrs)-1].(*ssa.Return)
+		return ok
+	}
+
+	var queue []*ssa.BasicBlock
+	start := from.Block()
+	for i, instr := range start.Instrs {
+		if instr == from && scan(start.Instrs[i+1:]) {
+			if returns(start) {
+				return true
+			}
+			queue = append(queue, start.Succs...)
+		}
+	}
+	seen := make(map[*ssa.BasicBlock]bool)
+	for len(queue) > 0 {
+		b := queue[0]
+		queue = queue[1:]
+		if seen[b] {
+			continue
+		}
+		seen[b] = true
+		if scan(b.Instrs) {
+			if returns(b) {
+				return true
+			}
+			queue = append(queue, b.Succs...)
+		}
+	}
+	return false
+}
+
+// inCycle reports whether b is part of a loop of its function.
+func inCycle(b *ssa.BasicBlock) bool {
+	seen := make(map[*ssa.BasicBlock]bool)
+	queue := append([]*ssa.BasicBlock{}, b.Succs...)
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		if next == b {
+			return true
+		}
+		if !seen[next] {
+			seen[next] = true
+			queue = append(queue, next.Succs...)
+		}
+	}
+	return false
+}
+
+// ssaFuncName names fn the way analyzeFile names the function enclosing an
+// operation: package name, receiver type name for methods, and a .funcN
+// suffix per level of function literal.
+func ssaFuncName(fn *ssa.Function) string {
+	if parent := fn.Parent(); parent != nil {
+		for i, anon := range parent.AnonFuncs {
+			if anon == fn {
+				return fmt.Sprintf("%s.func%d", ssaFuncName(parent), i+1)
+			}
+		}
+	}
+	if fn.Origin() != nil {
+		fn = fn.Origin()
+	}
+	name := fn.Name()
+	if recv := fn.Signature.Recv(); recv != nil {
+		t := recv.Type()
+		if ptr, ok := t.(*types.Pointer); ok {
+			t = ptr.Elem()
+		}
+		if named, ok := t.(*types.Named); ok {
+			name = named.Obj().Name() + "." + name
+		}
+	}
+	if fn.Pkg != nil {
+		name = fn.Pkg.Pkg.Name() + "." + name
+	}
+	return name
+}
+
+// trace records every operation reachable from v on channel, which is
+// tracked under key. Variables other than key that v flows into are listed
+// as aliases of the channel.
+func (a *ssaAnalysis) trace(key types.Object, channel *ChannelInfo, v ssa.Value) {
+	visited := a.visited[channel]
+	if visited == nil {
+		visited = make(map[ssa.Value]bool)
+		a.visited[channel] = visited
+	}
+
+	queue := []ssa.Value{v}
+	push := func(values ...ssa.Value) {
+		for _, v := range values {
+			if v != nil && !visited[v] {
+				queue = append(queue, v)
+			}
+		}
+	}
+	record := func(ops *[]Operation, op Operation) {
+		*ops = append(*ops, op)
+		file := op.Location[:strings.LastIndex(op.Location, ":")]
+		channel.UsedInFiles = appendIfNotExists(channel.UsedInFiles, file)
+	}
+
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+		if visited[v] {
+			continue
+		}
+		visited[v] = true
+
+		refs := v.Referrers()
+		if refs == nil {
+			continue
+		}
+		for _, ref := range *refs {
+			fn := ref.Parent()
+			switch x := ref.(type) {
+			case *ssa.Send:
+				if x.Chan == v {
+					op := a.op(fn, x.Pos())
+					op.ClosedAt = a.precedingClose(x, v)
+					record(&channel.SendOps, op)
+				}
+			case *ssa.UnOp:
+				if x.Op == token.ARROW {
+					if a.rangeFors[x.Pos()] {
+						record(&channel.RangeOps, a.op(fn, x.Pos()))
+					} else {
+						record(&channel.ReceiveOps, a.op(fn, x.Pos()))
+					}
+				}
+			case *ssa.Select:
+				for _, state := range x.States {
+					if state.Chan != v {
+						continue
+					}
+					op := a.op(fn, state.Pos)
+					op.Select = true
+					op.SelectCanGiveUp = ssaSelectCanGiveUp(x, state)
+					if state.Dir == types.SendOnly {
+						record(&channel.SendOps, op)
+					} else {
+						record(&channel.ReceiveOps, op)
+					}
+				}
+			case ssa.CallInstruction:
+				common := x.Common()
+				if builtin, ok := common.Value.(*ssa.Builtin); ok {
+					if builtin.Name() == "close" && len(common.Args) == 1 && common.Args[0] == v {
+						op := a.op(fn, x.Pos())
+						op.ClosedAt = a.precedingClose(x, v)
+						if !op.ClosedAt.IsValid() {
+							op.ClosedAt = a.possibleClose(x, v)
+							op.MayBeClosed = op.ClosedAt.IsValid()
+						}
+						record(&channel.CloseOps, op)
+					}
+					continue
+				}
+				for i, arg := range common.Args {
+					if arg != v {
+						continue
+					}
+					pass := a.op(fn, x.Pos())
+					// Interface method calls pass the receiver separately.
+					index := i
+					if common.IsInvoke() {
+						index++
+					}
+					callees := a.callees(x)
+					pass.external = len(callees) == 0
+					for _, callee := range callees {
+						if callee.Blocks == nil && callee.Origin() != nil {
+							callee = callee.Origin()
+						}
+						if callee.Pkg == nil || !a.initial[callee.Pkg.Pkg] {
+							pass.external = true
+						}
+						params := len(callee.Params)
+						if callee.Signature.Variadic() {
+							params-- // the variadic slice holds copies, not the argument
+						}
+						if index < params {
+							push(callee.Params[index])
+						}
+					}
+					record(&channel.PassedTo, pass)
+				}
+			case *ssa.Return:
+				record(&channel.ReturnedFrom, a.op(fn, x.Pos()))
+				for i, result := range x.Results {
+					if result != v {
+						continue
+					}
+					a.traceResult(fn, i, len(x.Results), push)
+				}
+			case *ssa.Store:
+				if x.Val == v {
+					if loc, ok := a.locOf(x.Addr); ok {
+						push(a.loads[loc]...)
+					}
+				}
+			case *ssa.MapUpdate:
+				if x.Value == v {
+					push(a.loads[a.elemLoc(x.Map)]...)
+				}
+			case *ssa.MakeClosure:
+				closure := x.Fn.(*ssa.Function)
+				for i, binding := range x.Bindings {
+					if binding == v && i < len(closure.FreeVars) {
+						push(closure.FreeVars[i])
+					}
+				}
+			case *ssa.DebugRef:
+				if obj, ok := x.Object().(*types.Var); ok && obj.Origin() != key {
+					position := a.fset.Position(obj.Pos())
+					channel.Aliases = appendIfNotExists(channel.Aliases, fmt.Sprintf("%s (%s) at %s:%d",
+						obj.Name(), channelKind(obj), position.Filename, position.Line))
+				}
+			case *ssa.Phi, *ssa.ChangeType, *ssa.MakeInterface, *ssa.TypeAssert:
+				push(x.(ssa.Value))
+			case *ssa.Extract:
+				// The value of a comma-ok lookup or type assertion.
+				if x.Index == 0 {
+					push(x)
+				}
+			}
+		}
+	}
+}
+
+// sameChannel returns the values holding the channel v in the function fn:
+// v itself or, when v is loaded from a local variable, as those captured by
+// closures are, every load of the variable in fn. defs are the
+// instructions after which they may hold another channel: the one defining
+// v, or the allocation of the variable and the stores to it.
+func (a *ssaAnalysis) sameChannel(fn *ssa.Function, v ssa.Value) (values []ssa.Value, defs []ssa.Instruction) {
+	if load, ok := v.(*ssa.UnOp); ok && load.Op == token.MUL {
+		if loc, ok := a.locOf(load.X); ok {
+			if alloc, ok := loc.value.(*ssa.Alloc); ok && !loc.elem {
+				for _, l := range a.loads[loc] {
+					if l.Parent() == fn {
+						values = append(values, l)
+					}
+				}
+				if alloc.Parent() == fn {
+					defs = append(defs, alloc)
+				}
+				for _, b := range fn.Blocks {
+					for _, instr := range b.Instrs {
+						if store, ok := instr.(*ssa.Store); ok {
+							if l, ok := a.locOf(store.Addr); ok && l == loc {
+								defs = append(defs, store)
+							}
+						}
+					}
+				}
+				return values, defs
+			}
+		}
+	}
+	if def, ok := v.(ssa.Instruction); ok {
+		defs = append(defs, def)
+	}
+	return []ssa.Value{v}, defs
+}
+
+// closesOf returns the calls of close on the channel v in fn, with the
+// instructions after which v may hold another channel, as sameChannel.
+func (a *ssaAnalysis) closesOf(fn *ssa.Function, v ssa.Value) (closes []*ssa.Call, defs []ssa.Instruction) {
+	values, defs := a.sameChannel(fn, v)
+	for _, value := range values {
+		for _, ref := range *value.Referrers() {
+			call, ok := ref.(*ssa.Call)
+			if !ok || call.Parent() != fn {
+				continue
+			}
+			builtin, ok := call.Call.Value.(*ssa.Builtin)
+			if ok && builtin.Name() == "close" && len(call.Call.Args) == 1 && call.Call.Args[0] == value {
+				closes = append(closes, call)
+			}
+		}
+	}
+	return closes, defs
+}
+
+// precedingClose returns the position of a close(v) that dominates instr,
+// so that it runs before instr on every path, and that v is not made or
+// assigned again before, or the zero Position if there is none.
+func (a *ssaAnalysis) precedingClose(instr ssa.Instruction, v ssa.Value) token.Position {
+	closes, defs := a.closesOf(instr.Parent(), v)
+	for _, call := range closes {
+		if call == instr || !dominates(call, instr) {
+			continue
+		}
+		fresh := false
+		for _, def := range defs {
+			fresh = fresh || !dominates(def, call)
+		}
+		if !fresh {
+			return a.op(call.Parent(), call.Pos()).Position
+		}
+	}
+	return token.Position{}
+}
+
+// dominates reports whether the instruction a runs before b, of the same
+// function, on every path to b.
+func dominates(a, b ssa.Instruction) bool {
+	if a.Block() == b.Block() {
+		return precedes(a, b)
+	}
+	return a.Block().Dominates(b.Block())
+}
+
+// possibleClose returns the position of a close(v) that may run before the
+// close instr on some path through their function: another close, or instr
+// itself in a loop, from which control can reach instr without making v
+// again, or any other close when instr is deferred and so runs when the
+// function returns. Deferred closes run last and are never taken to run
+// before. It returns the zero Position if there is none.
+func (a *ssaAnalysis) possibleClose(instr ssa.CallInstruction, v ssa.Value) token.Position {
+	_, deferred := instr.(*ssa.Defer)
+	closes, defs := a.closesOf(instr.Parent(), v)
+	for _, call := range closes {
+		if deferred || reaches(call, instr, defs) {
+			return a.op(call.Parent(), call.Pos()).Position
+		}
+	}
+	return token.Position{}
+}
+
+// reaches reports whether control can flow from the instruction from to the
+// instruction to of the same function, through a loop when to does not
+// come after from, without passing one of defs, which define the value
+// they use.
+func reaches(from, to ssa.Instruction, defs []ssa.Instruction) bool {
+	// scan walks instrs until to or one of defs, and reports whether it
+	// found to, and whether it stopped.
+	scan := func(instrs []ssa.Instruction) (found, stopped bool) {
+		for _, instr := range instrs {
+			if instr == to {
+				return true, true
+			}
+			if slices.Contains(defs, instr) {
+				return false, true
+			}
+		}
+		return false, false
+	}
+
+	start := from.Block()
+	found, stopped := scan(start.Instrs[slices.Index(start.Instrs, from)+1:])
+	if stopped {
+		return found
+	}
+	seen := make(map[*ssa.BasicBlock]bool)
+	queue := append([]*ssa.BasicBlock{}, start.Succs...)
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		if seen[next] {
+			continue
+		}
+		seen[next] = true
+		found, stopped := scan(next.Instrs)
+		if found {
+			return true
+		}
+		if !stopped {
+			queue = append(queue, next.Succs...)
+		}
+	}
+	return false
+}
+
+// precedes reports whether a comes before b in their common block.
+func precedes(a, b ssa.Instruction) bool {
+	for _, instr := range a.Block().Instrs {
+		switch instr {
+		case a:
+			return true
+		case b:
+			return false
+		}
+	}
+	return false
+}
+
+// traceResult follows result i of n returned by fn into its callers.
+func (a *ssaAnalysis) traceResult(fn *ssa.Function, i, n int, push func(...ssa.Value)) {
+	node := a.cg.Nodes[fn]
+	if node == nil {
+		return
+	}
+	for _, edge := range node.In {
+		call, ok := edge.Site.(*ssa.Call)
+		if !ok {
+			continue
+		}
+		if n == 1 {
+			push(call)
+			continue
+		}
+		for _, ref := range *call.Referrers() {
+			if extract, ok := ref.(*ssa.Extract); ok && extract.Index == i {
+				push(extract)
+			}
+		}
+	}
+}
+
+// ssaSelectCanGiveUp is the SSA counterpart of selectCanGiveUp: it reports
+// whether sel has a default case or a case other than state receiving from
+// a timer or a Done() channel.
+func ssaSelectCanGiveUp(sel *ssa.Select, state *ssa.SelectState) bool {
+	if !sel.Blocking {
+		return true
+	}
+	for _, other := range sel.States {
+		if other == state || other.Dir != types.RecvOnly {
+			continue
+		}
+		switch ch := other.Chan.(type) {
+		case *ssa.Call:
+			if callee := ch.Common().StaticCallee(); callee != nil && callee.Object() != nil {
+				if fn, ok := callee.Object().(*types.Func); ok && isCancelFunc(fn) {
+					return true
+				}
+			}
+			if ch.Common().IsInvoke() && isCancelFunc(ch.Common().Method) {
+				return true
+			}
+		case *ssa.UnOp:
+			if addr, ok := ch.X.(*ssa.FieldAddr); ok && isTimerField(structField(addr.X.Type(), addr.Field)) {
+				return true
+			}
+		}
+	}
+	return false
+}
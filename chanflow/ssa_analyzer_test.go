@@ -0,0 +1,158 @@
+package chanflow_test
+
+import (
+	"context"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"channeling/chanflow"
+)
+
+func TestSSABackend(t *testing.T) {
+	for _, pkg := range []string{"usage", "deadlock", "leak", "closes", "ownership", "aliases"} {
+		t.Run(pkg, func(t *testing.T) {
+			report := analyzeFixture(t, pkg, chanflow.Options{Backend: chanflow.BackendSSA})
+			checkWants(t, pkg, report)
+		})
+	}
+}
+
+// TestBackendPositions checks that both backends report the diagnostics of
+// the fixtures, and the operations they both find, at the same positions,
+// columns included.
+func TestBackendPositions(t *testing.T) {
+	// diagnostics lists the rule and positions of every diagnostic of
+	// report.
+	diagnostics := func(report *chanflow.Report) []string {
+		var list []string
+		for _, d := range report.Diagnostics {
+			entry := fmt.Sprintf("%s at %s", d.Rule, d.Position)
+			for _, r := range d.Related {
+				entry += fmt.Sprintf(", related %s", r)
+			}
+			list = append(list, entry)
+		}
+		slices.Sort(list)
+		return list
+	}
+	// columns returns the columns of the operations of report by channel
+	// and line.
+	columns := func(report *chanflow.Report) map[string]int {
+		cols := make(map[string]int)
+		for _, c := range report.Channels {
+			for _, op := range slices.Concat(c.MakeOps, c.SendOps, c.ReceiveOps, c.CloseOps, c.RangeOps, c.PassedTo) {
+				cols[fmt.Sprintf("%s at %s:%d", c.Name, op.Position.Filename, op.Position.Line)] = op.Position.Column
+			}
+		}
+		return cols
+	}
+	for _, pkg := range []string{"usage", "deadlock", "leak", "closes", "ownership", "aliases"} {
+		t.Run(pkg, func(t *testing.T) {
+			ast := analyzeFixture(t, pkg, chanflow.Options{Backend: chanflow.BackendAST})
+			ssa := analyzeFixture(t, pkg, chanflow.Options{Backend: chanflow.BackendSSA})
+			if want, got := diagnostics(ast), diagnostics(ssa); !slices.Equal(want, got) {
+				t.Errorf("SSA backend reports\n\t%s\nwant, as the AST backend,\n\t%s",
+					strings.Join(got, "\n\t"), strings.Join(want, "\n\t"))
+			}
+			want := columns(ast)
+			for op, col := range columns(ssa) {
+				if wantCol, ok := want[op]; ok && col != wantCol {
+					t.Errorf("SSA backend reports the operation on %s at column %d, want %d", op, col, wantCol)
+				}
+			}
+		})
+	}
+}
+
+// analyzeFixture runs Analyze with opts over the package pkg of
+// testdata/src, loaded in GOPATH mode as analysistest loads it.
+func analyzeFixture(t *testing.T, pkg string, opts chanflow.Options) *chanflow.Report {
+	t.Helper()
+	testdata, err := filepath.Abs("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.Dir = filepath.Join(testdata, "src", filepath.FromSlash(pkg))
+	opts.Env = append(opts.Env, "GO111MODULE=off", "GOPATH="+testdata, "GOFLAGS=")
+	report, err := chanflow.Analyze(context.Background(), []string{"."}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range report.PackageErrors {
+		t.Errorf("%s: %s", e.Package, e.Message)
+	}
+	return report
+}
+
+// wantPattern matches the diagnostic patterns of a // want comment, leaving
+// out the name:"pattern" expectations of facts.
+var wantPattern = regexp.MustCompile("(?:^|\\s)(\"(?:[^\"\\\\]|\\\\.)*\"|`[^`]*`)")
+
+// checkWants reports the diagnostics of report that no // want comment of
+// the files of pkg expects on their line, and the expectations left
+// unmatched.
+func checkWants(t *testing.T, pkg string, report *chanflow.Report) {
+	t.Helper()
+	dir := filepath.Join("testdata", "src", filepath.FromSlash(pkg))
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wants := make(map[string][]*regexp.Regexp)
+	fset := token.NewFileSet()
+	for _, name := range files {
+		abs, err := filepath.Abs(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file, err := parser.ParseFile(fset, abs, nil, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, group := range file.Comments {
+			for _, c := range group.List {
+				text, ok := strings.CutPrefix(c.Text, "// want ")
+				if !ok {
+					continue
+				}
+				line := fmt.Sprintf("%s:%d", abs, fset.Position(c.Pos()).Line)
+				for _, m := range wantPattern.FindAllStringSubmatch(text, -1) {
+					pattern, err := strconv.Unquote(m[1])
+					if err != nil {
+						t.Fatalf("%s: %v", line, err)
+					}
+					wants[line] = append(wants[line], regexp.MustCompile(pattern))
+				}
+			}
+		}
+	}
+
+	for _, d := range report.Diagnostics {
+		line := fmt.Sprintf("%s:%d", d.Position.Filename, d.Position.Line)
+		patterns := wants[line]
+		matched := -1
+		for i, pattern := range patterns {
+			if pattern.MatchString(d.Message) {
+				matched = i
+				break
+			}
+		}
+		if matched < 0 {
+			t.Errorf("%s: unexpected diagnostic: %s", line, d.Message)
+			continue
+		}
+		wants[line] = append(patterns[:matched], patterns[matched+1:]...)
+	}
+	for line, patterns := range wants {
+		for _, pattern := range patterns {
+			t.Errorf("%s: no diagnostic matching %q", line, pattern)
+		}
+	}
+}
@@ -0,0 +1,1305 @@
+package chanflow
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"runtime"
+	"slices"
+	"sort"
+	"sync"
+
+	"golang.org/x/tools/go/cfg"
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/types/objectpath"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// analyzeSyntax finds the channels of pkgs by matching their type-checked
+// syntax trees, follows them across calls, returns and struct fields and
+// runs every check over them.
+func analyzeSyntax(ctx context.Context, fset *token.FileSet, pkgs []*packages.Package, workers int, t *tracker) ([]*ChannelInfo, error) {
+	facts, err := collectFacts(ctx, fset, pkgs, workers, t)
+	if err != nil {
+		return nil, err
+	}
+	var files []*fileFacts
+	for _, f := range facts {
+		files = append(files, f...)
+	}
+	return resolveFiles(files), nil
+}
+
+// collectFacts analyzes the files of pkgs and returns their facts, package
+// by package in the order of pkgs. Files are analyzed concurrently by
+// workers goroutines, each on its own, so that the result does not depend
+// on scheduling. No more files are started once ctx is done.
+func collectFacts(ctx context.Context, fset *token.FileSet, pkgs []*packages.Package, workers int, t *tracker) ([][]*fileFacts, error) {
+	type fileJob struct {
+		pkg, file int
+	}
+	facts := make([][]*fileFacts, len(pkgs))
+	var jobs []fileJob
+	for i, pkg := range pkgs {
+		if pkg.TypesInfo == nil {
+			continue
+		}
+		facts[i] = make([]*fileFacts, len(pkg.Syntax))
+		for j := range pkg.Syntax {
+			jobs = append(jobs, fileJob{pkg: i, file: j})
+		}
+	}
+
+	t.update(func(p *Progress) {
+		p.Stage = StageAnalyze
+		p.Files = len(jobs)
+	})
+
+	next := make(chan fileJob)
+	var wg sync.WaitGroup
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range next {
+				pkg := pkgs[job.pkg]
+				facts[job.pkg][job.file] = analyzeFile(fset, pkg.TypesInfo, pkg.Syntax[job.file])
+				t.update(func(p *Progress) { p.FilesAnalyzed++ })
+			}
+		}()
+	}
+queue:
+	for _, job := range jobs {
+		select {
+		case next <- job:
+		case <-ctx.Done():
+			break queue
+		}
+	}
+	close(next)
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return facts, nil
+}
+
+// resolveFiles merges the facts of files, attributes operations to the
+// goroutines running them, follows flows and runs every check over the
+// resulting channels.
+func resolveFiles(files []*fileFacts) []*ChannelInfo {
+	channels := make(map[string]*ChannelInfo)
+	spawns, calls, flows := mergeFiles(channels, files)
+	attributeGoroutines(channels, spawns, calls)
+	resolveFlows(channels, flows)
+	list := make([]*ChannelInfo, 0, len(channels))
+	for _, channel := range channels {
+		list = append(list, channel)
+	}
+	runChecks(list)
+	return list
+}
+
+// fileFacts is what analyzeFile finds in a single file: the channels it
+// declares, the operations on variables that may hold a channel, declared in
+// this file or another one, the flows between them, the go statements and
+// the calls of functions declared anywhere. Variables and functions are
+// identified by objectKey, so that facts can be kept across runs and merged
+// with those of files type-checked separately.
+type fileFacts struct {
+	Path   string
+	Decls  []fileDecl
+	Ops    []fileOp
+	Flows  []flow
+	Spawns []fileSpawn
+	Calls  []fileCall
+}
+
+// fileDecl is a channel variable declared in the file. Obj is only set in
+// the run that found it.
+type fileDecl struct {
+	Key     string
+	Channel *ChannelInfo
+	obj     types.Object
+}
+
+// fileOp is an operation on the channel held by the variable Key. Func is
+// the function declaration enclosing it when it does not run on a goroutine
+// started around a function literal, Capacity the buffer size of a make
+// and Param the objectKey of the parameter a pass stores the channel into,
+// if the callee is known. Spawner is set on the sends and receives of a
+// goroutine started around a function literal to the name of the function
+// starting it, when that function may return without reaching any
+// counterpart on the same variable.
+type fileOp struct {
+	Key      string
+	Kind     opKind
+	Op       Operation
+	Func     string
+	Capacity string
+	Param    string
+	Spawner  string
+}
+
+// fileSpawn is a go statement at Site, and Position, starting the function
+// Func. Multi marks a go statement that may run several times at once: in a
+// loop or on a goroutine that does.
+type fileSpawn struct {
+	Func     string
+	Site     string
+	Position token.Position
+	Multi    bool
+}
+
+// fileCall is a static call of the function Callee from the function
+// declaration Caller or, when Spawned is set, from a goroutine started
+// around a function literal.
+type fileCall struct {
+	Caller  string
+	Callee  string
+	Spawned bool
+}
+
+// opKind selects the list of a ChannelInfo an operation belongs to.
+type opKind int
+
+const (
+	opMake opKind = iota
+	opSend
+	opReceive
+	opClose
+	opRange
+	opReturn
+	opPass
+)
+
+// opsOf returns the list of operations of kind on c.
+func (c *ChannelInfo) opsOf(kind opKind) *[]Operation {
+	switch kind {
+	case opMake:
+		return &c.MakeOps
+	case opSend:
+		return &c.SendOps
+	case opReceive:
+		return &c.ReceiveOps
+	case opClose:
+		return &c.CloseOps
+	case opRange:
+		return &c.RangeOps
+	case opReturn:
+		return &c.ReturnedFrom
+	}
+	return &c.PassedTo
+}
+
+// mergeFiles adds the channels declared in files to channels, then the
+// operations on them, file after file. Operations on variables declared
+// nowhere in files or channels are dropped, and passes to functions not
+// declared in files marked as not followed. It returns the spawn sites of
+// the functions started with go f(), and the calls and flows of every
+// file.
+func mergeFiles(channels map[string]*ChannelInfo, files []*fileFacts) (map[string][]fileSpawn, []fileCall, []flow) {
+	declared := make(map[string]bool)
+	for _, f := range files {
+		for _, d := range f.Decls {
+			declared[d.Key] = true
+			if _, ok := channels[d.Key]; !ok {
+				// Merging leaves the facts as they were found, to be
+				// cached: appending to the clipped lists of the copy
+				// reallocates them.
+				channels[d.Key] = d.Channel.clone()
+			}
+		}
+	}
+
+	spawns := make(map[string][]fileSpawn)
+	var calls []fileCall
+	var flows []flow
+	for _, f := range files {
+		for _, o := range f.Ops {
+			channel := channels[o.Key]
+			if channel == nil {
+				continue
+			}
+			op := o.Op
+			op.fn = o.Func
+			op.spawner = o.Spawner
+			// Passes are only followed into the functions of files.
+			op.external = o.Kind == opPass && !declared[o.Param]
+			ops := channel.opsOf(o.Kind)
+			*ops = append(*ops, op)
+			if o.Kind == opMake {
+				channel.Capacities = appendIfNotExists(channel.Capacities, o.Capacity)
+			}
+			channel.UsedInFiles = appendIfNotExists(channel.UsedInFiles, f.Path)
+		}
+		flows = append(flows, f.Flows...)
+		calls = append(calls, f.Calls...)
+		for _, s := range f.Spawns {
+			spawns[s.Func] = append(spawns[s.Func], s)
+		}
+	}
+	return spawns, calls, flows
+}
+
+// channelObject resolves expr to the tracked variable it reads a channel
+// from. Selectors resolve to the struct field they name, index expressions
+// to the slice, array or map holding the element, and calls to the single
+// result of the callee. It returns nil when expr cannot be resolved.
+func channelObject(info *types.Info, expr ast.Expr) types.Object {
+	switch x := ast.Unparen(expr).(type) {
+	case *ast.Ident:
+		if obj, ok := info.ObjectOf(x).(*types.Var); ok {
+			return obj.Origin()
+		}
+	case *ast.SelectorExpr:
+		// Qualified identifiers (pkg.Var) have no selection and resolve
+		// through Sel like a plain identifier.
+		if sel, ok := info.Selections[x]; ok {
+			if sel.Kind() != types.FieldVal {
+				return nil
+			}
+			return sel.Obj().(*types.Var).Origin()
+		}
+		return channelObject(info, x.Sel)
+	case *ast.IndexExpr:
+		return channelObject(info, x.X)
+	case *ast.CallExpr:
+		if fn, ok := typeutil.Callee(info, x).(*types.Func); ok {
+			if results := fn.Origin().Signature().Results(); results.Len() == 1 {
+				return results.At(0)
+			}
+		}
+	}
+	return nil
+}
+
+// calleeParam returns the parameter of the statically known function called
+// by call that receives its argument at index, or nil.
+func calleeParam(info *types.Info, call *ast.CallExpr, index int) *types.Var {
+	fn := typeutil.StaticCallee(info, call)
+	if fn == nil {
+		return nil
+	}
+	params := fn.Origin().Signature().Params()
+	if fn.Signature().Variadic() && index >= params.Len()-1 {
+		return nil
+	}
+	if index >= params.Len() {
+		return nil
+	}
+	return params.At(index)
+}
+
+// underlyingType returns the underlying type of expr, or nil when it has
+// none, as for undefined names in packages with type errors.
+func underlyingType(info *types.Info, expr ast.Expr) types.Type {
+	if t := info.TypeOf(expr); t != nil {
+		return t.Underlying()
+	}
+	return nil
+}
+
+// holdsChannel reports whether a variable of type t is a channel or a
+// slice, array or map whose elements are channels.
+func holdsChannel(t types.Type) bool {
+	switch u := t.Underlying().(type) {
+	case *types.Chan:
+		return true
+	case *types.Slice:
+		return holdsChannel(u.Elem())
+	case *types.Array:
+		return holdsChannel(u.Elem())
+	case *types.Map:
+		return holdsChannel(u.Elem())
+	}
+	return false
+}
+
+// channelType returns the channel type held by a variable of type t,
+// looking through slice, array and map elements.
+func channelType(t types.Type) *types.Chan {
+	switch u := t.Underlying().(type) {
+	case *types.Chan:
+		return u
+	case *types.Slice:
+		return channelType(u.Elem())
+	case *types.Array:
+		return channelType(u.Elem())
+	case *types.Map:
+		return channelType(u.Elem())
+	}
+	return nil
+}
+
+// channelDirection describes the direction of a channel type.
+func channelDirection(ch *types.Chan) string {
+	switch ch.Dir() {
+	case types.SendOnly:
+		return DirSend
+	case types.RecvOnly:
+		return DirReceive
+	default:
+		return DirBoth
+	}
+}
+
+// makeCapacity returns the buffer capacity of a make(chan T, n) call: the
+// constant value when n is constant and its source text otherwise.
+func makeCapacity(info *types.Info, call *ast.CallExpr) string {
+	if len(call.Args) < 2 {
+		return "0"
+	}
+	if tv, ok := info.Types[call.Args[1]]; ok && tv.Value != nil {
+		return tv.Value.ExactString()
+	}
+	return types.ExprString(call.Args[1])
+}
+
+// makeTarget resolves the tracked variable that the make call at the top of
+// stack is stored into, or nil when it is not stored into one.
+func makeTarget(info *types.Info, stack []ast.Node, sig *types.Signature) types.Object {
+	call := stack[len(stack)-1]
+	if len(stack) < 2 {
+		return nil
+	}
+	switch parent := stack[len(stack)-2].(type) {
+	case *ast.AssignStmt:
+		if len(parent.Lhs) != len(parent.Rhs) {
+			return nil
+		}
+		for i, rhs := range parent.Rhs {
+			if rhs == call {
+				return channelObject(info, parent.Lhs[i])
+			}
+		}
+	case *ast.ValueSpec:
+		for i, value := range parent.Values {
+			if value == call && i < len(parent.Names) {
+				return channelObject(info, parent.Names[i])
+			}
+		}
+	case *ast.KeyValueExpr:
+		// Struct literal fields: Worker{jobs: make(chan Job)}.
+		if parent.Value != call || len(stack) < 3 {
+			return nil
+		}
+		if lit, ok := stack[len(stack)-3].(*ast.CompositeLit); ok {
+			if _, ok := underlyingType(info, lit).(*types.Struct); ok {
+				return channelObject(info, parent.Key)
+			}
+		}
+	case *ast.ReturnStmt:
+		if sig == nil || len(parent.Results) != sig.Results().Len() {
+			return nil
+		}
+		for i, result := range parent.Results {
+			if result == call {
+				return sig.Results().At(i)
+			}
+		}
+	}
+	return nil
+}
+
+// channelKind describes where a channel variable is declared.
+func channelKind(obj *types.Var) string {
+	switch obj.Kind() {
+	case types.PackageVar:
+		return KindPackageVar
+	case types.FieldVar:
+		return KindField
+	case types.ParamVar, types.RecvVar:
+		return KindParam
+	case types.ResultVar:
+		return KindResult
+	default:
+		return KindLocal
+	}
+}
+
+// newChannel describes the channel variable obj, declared at pos, under the
+// given display name.
+func newChannel(fset *token.FileSet, obj *types.Var, name string, pos token.Pos) *ChannelInfo {
+	position := fset.Position(pos)
+	chanType := channelType(obj.Type())
+	return &ChannelInfo{
+		ID:           fmt.Sprintf("%s@%s", name, position),
+		Name:         name,
+		Kind:         channelKind(obj),
+		Type:         types.TypeString(obj.Type(), types.RelativeTo(obj.Pkg())),
+		ElemType:     types.TypeString(chanType.Elem(), types.RelativeTo(obj.Pkg())),
+		Direction:    channelDirection(chanType),
+		Location:     fmt.Sprintf("%s:%d", position.Filename, position.Line),
+		Position:     position,
+		Declaration:  fmt.Sprintf("Declared at %s:%d", position.Filename, position.Line),
+		SendOps:      make([]Operation, 0, 10),
+		ReceiveOps:   make([]Operation, 0, 10),
+		MakeOps:      make([]Operation, 0, 1),
+		CloseOps:     make([]Operation, 0, 2),
+		RangeOps:     make([]Operation, 0, 2),
+		ReturnedFrom: make([]Operation, 0, 5),
+		PassedTo:     make([]Operation, 0, 5),
+		UsedInFiles:  []string{position.Filename},
+		pos:          pos,
+	}
+}
+
+// objectKey identifies obj across packages and runs: by its package path
+// and object path when it can be reached from the package scope, as for
+// package variables, struct fields and the parameters of package-level
+// functions, and by the position of its declaration otherwise.
+func objectKey(fset *token.FileSet, enc *objectpath.Encoder, obj types.Object) string {
+	if obj.Pkg() != nil {
+		if path, err := enc.For(obj); err == nil {
+			return obj.Pkg().Path() + "." + string(path)
+		}
+	}
+	return fset.Position(obj.Pos()).String()
+}
+
+// funcFrame names a function being visited and counts the function
+// literals seen in it so far. fn is the enclosing function declaration and
+// goroutine the spawn site, at goroutinePos, when the frame runs on a
+// goroutine started by a go statement around a function literal, which
+// multi marks when several instances of it may run at once.
+type funcFrame struct {
+	name         string
+	sig          *types.Signature
+	fn           *types.Func
+	goroutine    string
+	goroutinePos token.Position
+	multi        bool
+	lits         int
+}
+
+// funcDeclName returns the name of a function declaration, qualified by the
+// receiver type name for methods.
+func funcDeclName(decl *ast.FuncDecl) string {
+	if decl.Recv == nil || len(decl.Recv.List) == 0 {
+		return decl.Name.Name
+	}
+	recv := decl.Recv.List[0].Type
+	for {
+		switch t := recv.(type) {
+		case *ast.StarExpr:
+			recv = t.X
+			continue
+		case *ast.IndexExpr:
+			recv = t.X
+			continue
+		case *ast.IndexListExpr:
+			recv = t.X
+			continue
+		case *ast.Ident:
+			return t.Name + "." + decl.Name.Name
+		}
+		return decl.Name.Name
+	}
+}
+
+// spawnedLiteral reports whether the function literal at the top of stack
+// is the function of a go statement, as in go func() { ... }().
+func spawnedLiteral(stack []ast.Node) (*ast.GoStmt, bool) {
+	if len(stack) < 3 {
+		return nil, false
+	}
+	call, ok := stack[len(stack)-2].(*ast.CallExpr)
+	if !ok || ast.Unparen(call.Fun) != stack[len(stack)-1] {
+		return nil, false
+	}
+	goStmt, ok := stack[len(stack)-3].(*ast.GoStmt)
+	return goStmt, ok && goStmt.Call == call
+}
+
+// inLoop reports whether the node at the top of stack runs in a loop of the
+// function enclosing it.
+func inLoop(stack []ast.Node) bool {
+	for i := len(stack) - 2; i >= 0; i-- {
+		switch stack[i].(type) {
+		case *ast.ForStmt, *ast.RangeStmt:
+			return true
+		case *ast.FuncLit, *ast.FuncDecl:
+			return false
+		}
+	}
+	return false
+}
+
+// selectCase returns the select statement and case whose communication is
+// the send statement or receive expression at the top of stack, or nil if
+// it is not one.
+func selectCase(stack []ast.Node) (*ast.SelectStmt, *ast.CommClause) {
+	comm := stack[len(stack)-1]
+	for i := len(stack) - 2; i >= 2; i-- {
+		switch parent := stack[i].(type) {
+		case *ast.CommClause:
+			if parent.Comm != comm {
+				return nil, nil
+			}
+			sel, _ := stack[i-2].(*ast.SelectStmt)
+			return sel, parent
+		case *ast.ExprStmt, *ast.AssignStmt:
+			// case <-ch: and case v := <-ch: wrap the receive.
+			comm = parent
+		default:
+			return nil, nil
+		}
+	}
+	return nil, nil
+}
+
+// selectCanGiveUp reports whether sel has a case other than clause that
+// lets it stop waiting: a default case, or a receive from a timer or a
+// Done() channel such as ctx.Done().
+func selectCanGiveUp(info *types.Info, sel *ast.SelectStmt, clause *ast.CommClause) bool {
+	for _, stmt := range sel.Body.List {
+		other, ok := stmt.(*ast.CommClause)
+		if !ok || other == clause {
+			continue
+		}
+		var recv ast.Expr
+		switch comm := other.Comm.(type) {
+		case nil:
+			return true
+		case *ast.ExprStmt:
+			recv = comm.X
+		case *ast.AssignStmt:
+			recv = comm.Rhs[0]
+		}
+		if unary, ok := ast.Unparen(recv).(*ast.UnaryExpr); ok && unary.Op == token.ARROW {
+			if isCancelChan(info, unary.X) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isCancelChan reports whether expr is a channel that fires on its own to
+// end a wait: time.After, time.Tick, a timer's C field or a Done() method.
+func isCancelChan(info *types.Info, expr ast.Expr) bool {
+	switch x := ast.Unparen(expr).(type) {
+	case *ast.CallExpr:
+		fn, ok := typeutil.Callee(info, x).(*types.Func)
+		return ok && isCancelFunc(fn)
+	case *ast.SelectorExpr:
+		if sel, ok := info.Selections[x]; ok && sel.Kind() == types.FieldVal {
+			return isTimerField(sel.Obj())
+		}
+	}
+	return false
+}
+
+// isCancelFunc reports whether fn returns a channel that fires on its own:
+// time.After, time.Tick or a Done method like context.Context's.
+func isCancelFunc(fn *types.Func) bool {
+	if fn.Pkg() != nil && fn.Pkg().Path() == "time" {
+		return fn.Name() == "After" || fn.Name() == "Tick"
+	}
+	return fn.Name() == "Done" && fn.Signature().Params().Len() == 0 && fn.Signature().Results().Len() == 1
+}
+
+// isTimerField reports whether field is the C field of time.Timer or
+// time.Ticker.
+func isTimerField(field types.Object) bool {
+	return field != nil && field.Pkg() != nil && field.Pkg().Path() == "time" && field.Name() == "C"
+}
+
+// closeArg returns the channel argument of call if it is a call of the
+// builtin close.
+func closeArg(info *types.Info, call *ast.CallExpr) (ast.Expr, bool) {
+	fun, ok := ast.Unparen(call.Fun).(*ast.Ident)
+	if !ok || len(call.Args) != 1 {
+		return nil, false
+	}
+	if builtin, ok := info.Uses[fun].(*types.Builtin); !ok || builtin.Name() != "close" {
+		return nil, false
+	}
+	return call.Args[0], true
+}
+
+// precedingClose returns the position of a close(expr) statement that runs
+// before the node at the top of stack on every path: an earlier statement
+// of a block enclosing it within the same function, closing the same
+// variable or field of the same variable, with no assignment to that
+// variable in between. Elements of slices, arrays and maps are never
+// matched, as their index may differ.
+func precedingClose(info *types.Info, stack []ast.Node, expr ast.Expr) token.Pos {
+	base := operandBase(info, expr)
+	if base == nil {
+		return token.NoPos
+	}
+	for i := len(stack) - 2; i >= 0; i-- {
+		var list []ast.Stmt
+		switch parent := stack[i].(type) {
+		case *ast.FuncDecl, *ast.FuncLit:
+			return token.NoPos
+		case *ast.BlockStmt:
+			list = parent.List
+		case *ast.CaseClause:
+			list = parent.Body
+		case *ast.CommClause:
+			list = parent.Body
+		}
+		for j, stmt := range list {
+			if stmt == stack[i+1] {
+				list = list[:j]
+				break
+			}
+		}
+		for j := len(list) - 1; j >= 0; j-- {
+			if stmt, ok := list[j].(*ast.ExprStmt); ok {
+				if call, ok := ast.Unparen(stmt.X).(*ast.CallExpr); ok {
+					if arg, ok := closeArg(info, call); ok && sameOperand(info, arg, expr) {
+						return call.Pos()
+					}
+				}
+			}
+			if assigns(info, list[j], base) {
+				return token.NoPos
+			}
+		}
+	}
+	return token.NoPos
+}
+
+// possibleClose returns the position of a close(expr) that may run before
+// the close call at the top of stack on some path through the function
+// enclosing it: another close, or the same one in a loop, from which
+// control can reach it without an assignment to the variable of expr, or
+// any other close when the one at the top of stack is deferred and so runs
+// when the function returns. graph returns the control flow graph of a
+// function body.
+func possibleClose(info *types.Info, stack []ast.Node, expr ast.Expr, graph func(*ast.BlockStmt) *cfg.CFG) token.Pos {
+	base := operandBase(info, expr)
+	if base == nil || len(stack) < 2 {
+		return token.NoPos
+	}
+	call := stack[len(stack)-1]
+	target := stack[len(stack)-2]
+	body := enclosingBody(stack[:len(stack)-1])
+	if body == nil {
+		return token.NoPos
+	}
+
+	// The closes of the operand in the function, by statement, in the
+	// order of the source.
+	type closeStmt struct {
+		stmt ast.Node
+		call *ast.CallExpr
+	}
+	var closes []closeStmt
+	ast.Inspect(body, func(n ast.Node) bool {
+		var other *ast.CallExpr
+		switch x := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.ExprStmt:
+			other, _ = ast.Unparen(x.X).(*ast.CallExpr)
+		case *ast.DeferStmt:
+			other = x.Call
+		}
+		if other == nil {
+			return true
+		}
+		if arg, ok := closeArg(info, other); ok && sameOperand(info, arg, expr) {
+			closes = append(closes, closeStmt{stmt: n, call: other})
+		}
+		return true
+	})
+
+	switch target.(type) {
+	case *ast.DeferStmt:
+		for _, c := range closes {
+			if c.call != call {
+				return c.call.Pos()
+			}
+		}
+		return token.NoPos
+	case *ast.ExprStmt:
+	default:
+		return token.NoPos
+	}
+	g := graph(body)
+	for _, c := range closes {
+		if _, deferred := c.stmt.(*ast.DeferStmt); !deferred && flowsTo(info, g, c.stmt, target, base) {
+			return c.call.Pos()
+		}
+	}
+	return token.NoPos
+}
+
+// flowsTo reports whether control can flow in g from the node from to the
+// node to, possibly through a loop back to to itself, without passing a
+// node that assigns v.
+func flowsTo(info *types.Info, g *cfg.CFG, from, to ast.Node, v types.Object) bool {
+	// scan walks nodes until to or an assignment of v, and reports whether
+	// it went past the end of them.
+	found := false
+	scan := func(nodes []ast.Node) bool {
+		for _, n := range nodes {
+			if n == to {
+				found = true
+				return false
+			}
+			if assigns(info, n, v) {
+				return false
+			}
+		}
+		return true
+	}
+	var queue []*cfg.Block
+	for _, b := range g.Blocks {
+		for i, n := range b.Nodes {
+			if n == from && scan(b.Nodes[i+1:]) {
+				queue = append(queue, b.Succs...)
+			}
+		}
+	}
+	seen := make(map[*cfg.Block]bool)
+	for len(queue) > 0 && !found {
+		b := queue[0]
+		queue = queue[1:]
+		if seen[b] {
+			continue
+		}
+		seen[b] = true
+		if scan(b.Nodes) {
+			queue = append(queue, b.Succs...)
+		}
+	}
+	return found
+}
+
+// enclosingBody returns the body of the innermost function declaration or
+// literal in stack, or nil if there is none.
+func enclosingBody(stack []ast.Node) *ast.BlockStmt {
+	for i := len(stack) - 1; i >= 0; i-- {
+		switch fn := stack[i].(type) {
+		case *ast.FuncDecl:
+			return fn.Body
+		case *ast.FuncLit:
+			return fn.Body
+		}
+	}
+	return nil
+}
+
+// returnsAvoiding reports whether control can flow in g from the node from
+// to a return of the function without passing a node enclosing one of
+// positions, or the head of a range loop at one. Paths ending in a call of
+// panic do not return.
+func returnsAvoiding(info *types.Info, g *cfg.CFG, from ast.Node, positions []token.Pos) bool {
+	// scan walks nodes until one enclosing a position, and reports whether
+	// it went past the end of them.
+	scan := func(nodes []ast.Node) bool {
+		for _, n := range nodes {
+			for _, pos := range positions {
+				if n.Pos() <= pos && pos < n.End() {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	// head reports whether b receives for a range loop at one of positions.
+	head := func(b *cfg.Block) bool {
+		loop, ok := b.Stmt.(*ast.RangeStmt)
+		return ok && b.Kind == cfg.KindRangeLoop && slices.Contains(positions, loop.For)
+	}
+	returns := func(b *cfg.Block) bool {
+		if len(b.Succs) > 0 {
+			return false
+		}
+		if len(b.Nodes) > 0 {
+			if stmt, ok := b.Nodes[len(b.Nodes)-1].(*ast.ExprStmt); ok {
+				if call, ok := ast.Unparen(stmt.X).(*ast.CallExpr); ok && !mayReturn(info)(call) {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	var queue []*cfg.Block
+	for _, b := range g.Blocks {
+		for i, n := range b.Nodes {
+			if n == from && scan(b.Nodes[i+1:]) {
+				if returns(b) {
+					return true
+				}
+				queue = append(queue, b.Succs...)
+			}
+		}
+	}
+	seen := make(map[*cfg.Block]bool)
+	for len(queue) > 0 {
+		b := queue[0]
+		queue = queue[1:]
+		if seen[b] {
+			continue
+		}
+		seen[b] = true
+		if !head(b) && scan(b.Nodes) {
+			if returns(b) {
+				return true
+			}
+			queue = append(queue, b.Succs...)
+		}
+	}
+	return false
+}
+
+// loopedAt reports whether pos is in a part of a loop in n that runs once
+// per iteration: its condition, post statement or body. The head of a range
+// loop runs at least once and does not count.
+func loopedAt(n ast.Node, pos token.Pos) bool {
+	found := false
+	ast.Inspect(n, func(n ast.Node) bool {
+		var start, end token.Pos
+		switch x := n.(type) {
+		case *ast.ForStmt:
+			start, end = x.Body.Pos(), x.End()
+			if x.Post != nil {
+				start = x.Post.Pos()
+			}
+			if x.Cond != nil {
+				start = x.Cond.Pos()
+			}
+		case *ast.RangeStmt:
+			start, end = x.Body.Pos(), x.End()
+		}
+		found = found || start <= pos && pos < end
+		return !found
+	})
+	return found
+}
+
+// mayReturn reports whether call may return, as any call but one of the
+// builtin panic does, for cfg.New.
+func mayReturn(info *types.Info) func(*ast.CallExpr) bool {
+	return func(call *ast.CallExpr) bool {
+		fun, ok := ast.Unparen(call.Fun).(*ast.Ident)
+		if !ok {
+			return true
+		}
+		builtin, ok := info.Uses[fun].(*types.Builtin)
+		return !ok || builtin.Name() != "panic"
+	}
+}
+
+// operandBase returns the variable at the root of a channel operand made of
+// identifiers, field selections and pointer indirections, as v in v.a.b, or
+// nil for any other operand.
+func operandBase(info *types.Info, expr ast.Expr) types.Object {
+	switch x := ast.Unparen(expr).(type) {
+	case *ast.Ident:
+		return info.ObjectOf(x)
+	case *ast.SelectorExpr:
+		if _, ok := info.Selections[x]; ok {
+			return operandBase(info, x.X)
+		}
+		// A qualified identifier, pkg.Var.
+		return info.ObjectOf(x.Sel)
+	case *ast.StarExpr:
+		return operandBase(info, x.X)
+	}
+	return nil
+}
+
+// sameOperand reports whether a and b are the same chain of field
+// selections on the same variable, and so denote the same channel as long
+// as the variable is not assigned.
+func sameOperand(info *types.Info, a, b ast.Expr) bool {
+	switch x := ast.Unparen(a).(type) {
+	case *ast.Ident:
+		y, ok := ast.Unparen(b).(*ast.Ident)
+		return ok && info.ObjectOf(x) != nil && info.ObjectOf(x) == info.ObjectOf(y)
+	case *ast.SelectorExpr:
+		y, ok := ast.Unparen(b).(*ast.SelectorExpr)
+		if !ok || info.ObjectOf(x.Sel) == nil || info.ObjectOf(x.Sel) != info.ObjectOf(y.Sel) {
+			return false
+		}
+		if _, ok := info.Selections[x]; !ok {
+			return true
+		}
+		return sameOperand(info, x.X, y.X)
+	case *ast.StarExpr:
+		y, ok := ast.Unparen(b).(*ast.StarExpr)
+		return ok && sameOperand(info, x.X, y.X)
+	}
+	return false
+}
+
+// assigns reports whether stmt may change the variable v or a field of it:
+// by assigning to it, ranging into it or taking its address. A bare
+// operand of v, as the key or value of a range statement or the target of
+// a select case in a control flow graph, is taken as an assignment.
+func assigns(info *types.Info, stmt ast.Node, v types.Object) bool {
+	if expr, ok := stmt.(ast.Expr); ok && operandBase(info, expr) == v {
+		return true
+	}
+	found := false
+	ast.Inspect(stmt, func(n ast.Node) bool {
+		var targets []ast.Expr
+		switch x := n.(type) {
+		case *ast.AssignStmt:
+			targets = x.Lhs
+		case *ast.RangeStmt:
+			targets = []ast.Expr{x.Key, x.Value}
+		case *ast.UnaryExpr:
+			if x.Op == token.AND {
+				targets = []ast.Expr{x.X}
+			}
+		}
+		for _, target := range targets {
+			if target != nil && operandBase(info, target) == v {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// analyzeFile collects the channels, operations, flows and go statements of
+// the type-checked file node. It only reads node and info, so files can be
+// analyzed concurrently.
+func analyzeFile(fset *token.FileSet, info *types.Info, node *ast.File) *fileFacts {
+	filePath := fset.Position(node.Pos()).Filename
+	facts := &fileFacts{Path: filePath}
+	var enc objectpath.Encoder
+	key := func(obj types.Object) string {
+		return objectKey(fset, &enc, obj)
+	}
+	pkgInit := &funcFrame{name: node.Name.Name + ".init"}
+	// spawned lists the go statements around function literals, with the
+	// body and name of the function running them.
+	type spawnedLit struct {
+		stmt    *ast.GoStmt
+		body    *ast.BlockStmt
+		spawner string
+	}
+	var spawned []spawnedLit
+
+	// stack holds the nodes enclosing the one being visited and funcs the
+	// function declarations and literals among them.
+	var stack []ast.Node
+	funcs := []*funcFrame{pkgInit}
+	enclosing := func() *funcFrame {
+		return funcs[len(funcs)-1]
+	}
+
+	// lookup resolves expr to the variable it reads a channel from, or nil.
+	lookup := func(expr ast.Expr) types.Object {
+		if obj := channelObject(info, expr); obj != nil && holdsChannel(obj.Type()) {
+			return obj
+		}
+		return nil
+	}
+	closedAt := func(expr ast.Expr) token.Position {
+		if pos := precedingClose(info, stack, expr); pos.IsValid() {
+			return fset.Position(pos)
+		}
+		return token.Position{}
+	}
+	graphs := make(map[*ast.BlockStmt]*cfg.CFG)
+	graph := func(body *ast.BlockStmt) *cfg.CFG {
+		if graphs[body] == nil {
+			graphs[body] = cfg.New(body, mayReturn(info))
+		}
+		return graphs[body]
+	}
+	newOp := func(pos token.Pos) Operation {
+		frame := enclosing()
+		op := Operation{
+			Location:          fmt.Sprintf("%s:%d", filePath, fset.Position(pos).Line),
+			Func:              frame.name,
+			Goroutine:         frame.goroutine,
+			GoroutinePosition: frame.goroutinePos,
+			MultiInstance:     frame.multi,
+			Position:          fset.Position(pos),
+			pos:               pos,
+		}
+		return op
+	}
+	// record adds op on the channel held by obj, with the function
+	// declaration it runs in unless it runs on a goroutine of its own.
+	record := func(obj types.Object, kind opKind, op Operation) *fileOp {
+		o := fileOp{Key: key(obj), Kind: kind, Op: op}
+		if frame := enclosing(); frame.goroutine == "" && frame.fn != nil {
+			o.Func = key(frame.fn)
+		}
+		facts.Ops = append(facts.Ops, o)
+		return &facts.Ops[len(facts.Ops)-1]
+	}
+
+	ast.Inspect(node, func(n ast.Node) bool {
+		if n == nil {
+			switch stack[len(stack)-1].(type) {
+			case *ast.FuncDecl, *ast.FuncLit:
+				funcs = funcs[:len(funcs)-1]
+			}
+			stack = stack[:len(stack)-1]
+			return true
+		}
+		stack = append(stack, n)
+
+		facts.Flows = append(facts.Flows, nodeFlows(info, n, enclosing().sig, key)...)
+
+		switch x := n.(type) {
+		case *ast.FuncDecl:
+			frame := &funcFrame{name: node.Name.Name + "." + funcDeclName(x)}
+			if fn, ok := info.Defs[x.Name].(*types.Func); ok {
+				frame.sig = fn.Signature()
+				frame.fn = fn
+			}
+			funcs = append(funcs, frame)
+		case *ast.FuncLit:
+			parent := enclosing()
+			parent.lits++
+			frame := &funcFrame{
+				name:         fmt.Sprintf("%s.func%d", parent.name, parent.lits),
+				fn:           parent.fn,
+				goroutine:    parent.goroutine,
+				goroutinePos: parent.goroutinePos,
+				multi:        parent.multi,
+			}
+			frame.sig, _ = info.TypeOf(x).(*types.Signature)
+			if goStmt, ok := spawnedLiteral(stack); ok {
+				if body := enclosingBody(stack[:len(stack)-1]); body != nil {
+					spawned = append(spawned, spawnedLit{stmt: goStmt, body: body, spawner: parent.name})
+				}
+				frame.goroutinePos = fset.Position(goStmt.Pos())
+				frame.goroutine = fmt.Sprintf("%s:%d", filePath, frame.goroutinePos.Line)
+				frame.multi = parent.multi || inLoop(stack)
+			}
+			funcs = append(funcs, frame)
+		case *ast.GoStmt:
+			// Operations inside a function started with go f() are
+			// attributed to the goroutine once all spawn sites are known.
+			if callee := typeutil.StaticCallee(info, x.Call); callee != nil {
+				position := fset.Position(x.Pos())
+				facts.Spawns = append(facts.Spawns, fileSpawn{
+					Func:     key(callee.Origin()),
+					Site:     fmt.Sprintf("%s:%d", filePath, position.Line),
+					Position: position,
+					Multi:    enclosing().multi || inLoop(stack),
+				})
+			}
+		case *ast.Ident:
+			switch obj := info.Defs[x].(type) {
+			case *types.Var:
+				if obj.Name() != "_" && holdsChannel(obj.Type()) {
+					facts.Decls = append(facts.Decls, fileDecl{
+						Key:     key(obj.Origin()),
+						Channel: newChannel(fset, obj, obj.Name(), x.Pos()),
+						obj:     obj.Origin(),
+					})
+				}
+			case *types.Func:
+				// Unnamed results have no declaring identifier; track them
+				// under the function name so that <-f() resolves.
+				results := obj.Signature().Results()
+				for i := 0; i < results.Len(); i++ {
+					result := results.At(i)
+					if result.Name() != "" || !holdsChannel(result.Type()) {
+						continue
+					}
+					name := obj.Name() + "()"
+					if results.Len() > 1 {
+						name = fmt.Sprintf("%s()#%d", obj.Name(), i)
+					}
+					facts.Decls = append(facts.Decls, fileDecl{
+						Key:     key(result.Origin()),
+						Channel: newChannel(fset, result, name, x.Pos()),
+						obj:     result.Origin(),
+					})
+				}
+			}
+		case *ast.SendStmt:
+			if obj := lookup(x.Chan); obj != nil {
+				op := newOp(x.Pos())
+				if sel, clause := selectCase(stack); sel != nil {
+					op.Select = true
+					op.SelectCanGiveUp = selectCanGiveUp(info, sel, clause)
+				}
+				op.ClosedAt = closedAt(x.Chan)
+				record(obj, opSend, op)
+			}
+		case *ast.UnaryExpr:
+			if x.Op == token.ARROW {
+				if obj := lookup(x.X); obj != nil {
+					op := newOp(x.Pos())
+					if sel, clause := selectCase(stack); sel != nil {
+						op.Select = true
+						op.SelectCanGiveUp = selectCanGiveUp(info, sel, clause)
+					}
+					record(obj, opReceive, op)
+				}
+			}
+		case *ast.ReturnStmt:
+			for _, result := range x.Results {
+				if obj := lookup(result); obj != nil {
+					record(obj, opReturn, newOp(x.Pos()))
+				}
+			}
+		case *ast.RangeStmt:
+			if _, ok := underlyingType(info, x.X).(*types.Chan); !ok {
+				break
+			}
+			if obj := lookup(x.X); obj != nil {
+				record(obj, opRange, newOp(x.Pos()))
+			}
+		case *ast.CallExpr:
+			// close and other builtins are not function calls the channel
+			// escapes into.
+			if fun, ok := ast.Unparen(x.Fun).(*ast.Ident); ok {
+				if builtin, isBuiltin := info.Uses[fun].(*types.Builtin); isBuiltin {
+					if builtin.Name() == "make" && len(x.Args) > 0 {
+						if _, ok := underlyingType(info, x.Args[0]).(*types.Chan); !ok {
+							break
+						}
+						if obj := makeTarget(info, stack, enclosing().sig); obj != nil {
+							record(obj, opMake, newOp(x.Pos())).Capacity = makeCapacity(info, x)
+						}
+					}
+					if arg, ok := closeArg(info, x); ok {
+						if obj := lookup(arg); obj != nil {
+							op := newOp(x.Pos())
+							op.ClosedAt = closedAt(arg)
+							if !op.ClosedAt.IsValid() {
+								if pos := possibleClose(info, stack, arg, graph); pos.IsValid() {
+									op.ClosedAt = fset.Position(pos)
+									op.MayBeClosed = true
+								}
+							}
+							record(obj, opClose, op)
+						}
+					}
+					break
+				}
+			}
+			if callee := typeutil.StaticCallee(info, x); callee != nil {
+				call := fileCall{Callee: key(callee.Origin())}
+				if frame := enclosing(); frame.goroutine != "" {
+					call.Spawned = true
+				} else if frame.fn != nil {
+					call.Caller = key(frame.fn)
+				}
+				facts.Calls = append(facts.Calls, call)
+			}
+			for i, arg := range x.Args {
+				if obj := lookup(arg); obj != nil {
+					o := record(obj, opPass, newOp(x.Pos()))
+					if param := calleeParam(info, x, i); param != nil {
+						o.Param = key(param.Origin())
+					}
+				}
+			}
+		}
+		return true
+	})
+
+	// The function starting a goroutine around a literal may return without
+	// reaching any counterpart of its sends and receives on the same
+	// variable, leaving it blocked. Counterparts in loops are left alone, as
+	// the loops may run once per value they wait for.
+	for _, s := range spawned {
+		site := fset.Position(s.stmt.Pos())
+		for i := range facts.Ops {
+			o := &facts.Ops[i]
+			if o.Op.GoroutinePosition != site || o.Op.Select {
+				continue
+			}
+			var kinds []opKind
+			switch o.Kind {
+			case opSend:
+				kinds = []opKind{opReceive, opRange}
+			case opReceive, opRange:
+				kinds = []opKind{opSend, opClose}
+			default:
+				continue
+			}
+			var positions []token.Pos
+			selected := false
+			for _, other := range facts.Ops {
+				if other.Key != o.Key || other.Op.Goroutine != "" || other.Op.Func != s.spawner || !slices.Contains(kinds, other.Kind) {
+					continue
+				}
+				selected = selected || other.Op.Select || loopedAt(s.body, other.Op.pos)
+				positions = append(positions, other.Op.pos)
+			}
+			if len(positions) > 0 && !selected && returnsAvoiding(info, graph(s.body), s.stmt, positions) {
+				o.Spawner = s.spawner
+			}
+		}
+	}
+	return facts
+}
+
+// attributeGoroutines assigns the operations of functions started with
+// go f() to the goroutine spawned at the first such site, with several
+// instances when there are several sites or one may run several times. The
+// operations of the other functions called, directly or not, from
+// goroutines are marked as shared, since they run on each goroutine
+// calling them.
+func attributeGoroutines(channels map[string]*ChannelInfo, spawns map[string][]fileSpawn, calls []fileCall) {
+	for _, sites := range spawns {
+		sort.Slice(sites, func(i, j int) bool { return lessPosition(sites[i].Position, sites[j].Position) })
+	}
+	callees := make(map[string][]string)
+	var queue []string
+	for fn := range spawns {
+		queue = append(queue, fn)
+	}
+	for _, c := range calls {
+		if c.Spawned {
+			queue = append(queue, c.Callee)
+		} else if c.Caller != "" {
+			callees[c.Caller] = append(callees[c.Caller], c.Callee)
+		}
+	}
+	shared := make(map[string]bool)
+	for len(queue) > 0 {
+		fn := queue[0]
+		queue = queue[1:]
+		if !shared[fn] {
+			shared[fn] = true
+			queue = append(queue, callees[fn]...)
+		}
+	}
+
+	for _, channel := range channels {
+		for _, ops := range [][]Operation{
+			channel.SendOps, channel.ReceiveOps, channel.CloseOps,
+			channel.RangeOps, channel.ReturnedFrom, channel.PassedTo,
+		} {
+			for i := range ops {
+				if ops[i].fn == "" {
+					continue
+				}
+				ops[i].shared = shared[ops[i].fn]
+				if sites := spawns[ops[i].fn]; len(sites) > 0 {
+					ops[i].Goroutine = sites[0].Site
+					ops[i].GoroutinePosition = sites[0].Position
+					ops[i].MultiInstance = len(sites) > 1 || sites[0].Multi
+				}
+			}
+		}
+	}
+}
+
+func appendIfNotExists(slice []string, str string) []string {
+	for _, s := range slice {
+		if s == str {
+			return slice
+		}
+	}
+	return append(slice, str)
+}
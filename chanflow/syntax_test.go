@@ -0,0 +1,112 @@
+package chanflow_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"channeling/chanflow"
+)
+
+// TestWorkers checks that the report does not depend on the number of
+// files analyzed concurrently. Run it with -race.
+func TestWorkers(t *testing.T) {
+	// Every file of p uses the package-level channel shared and one of
+	// its own, and starts a goroutine.
+	files := map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.22\n",
+		"p/p.go": "package p\n\nvar shared = make(chan int)\n",
+	}
+	for i := range 16 {
+		files[fmt.Sprintf("p/f%d.go", i)] = fmt.Sprintf(`package p
+
+var own%[1]d = make(chan int, 1)
+
+func Send%[1]d() {
+	go func() { shared <- %[1]d }()
+	own%[1]d <- <-shared
+}
+
+func Close%[1]d() {
+	close(own%[1]d)
+	close(shared)
+}
+`, i)
+	}
+	dir := writeModule(t, files)
+
+	for _, backend := range []string{chanflow.BackendAST, chanflow.BackendSSA} {
+		t.Run(backend, func(t *testing.T) {
+			analyze := func(workers int) *chanflow.Report {
+				r, err := chanflow.Analyze(context.Background(), nil, chanflow.Options{Dir: dir, Backend: backend, Workers: workers})
+				if err != nil {
+					t.Fatal(err)
+				}
+				return r
+			}
+			compareReports(t, "example.com/m", analyze(1), analyze(8))
+			for _, pkg := range []string{"usage", "deadlock", "leak", "closes", "ownership", "aliases"} {
+				compareReports(t, pkg,
+					analyzeFixture(t, pkg, chanflow.Options{Backend: backend, Workers: 1}),
+					analyzeFixture(t, pkg, chanflow.Options{Backend: backend, Workers: 8}))
+			}
+		})
+	}
+}
+
+// compareReports reports the difference between the reports of name
+// analyzed with one worker and with several.
+func compareReports(t *testing.T, name string, one, several *chanflow.Report) {
+	t.Helper()
+	want, err := json.Marshal(one)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := json.Marshal(several)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("%s: report with several workers:\n%s\nwant, with one:\n%s", name, got, want)
+	}
+}
+
+// TestFirstSpawnSite checks that a function started from several go
+// statements runs on the goroutine named after the first of them, ordered
+// by line rather than as text.
+func TestFirstSpawnSite(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.22\n",
+		"p/p.go": `package p
+
+func worker(ch chan int) {
+	ch <- 1
+}
+
+func Run() {
+	ch := make(chan int)
+	go worker(ch)
+	go worker(ch)
+	<-ch
+}
+`,
+	})
+	for _, backend := range []string{chanflow.BackendAST, chanflow.BackendSSA} {
+		t.Run(backend, func(t *testing.T) {
+			r, err := chanflow.Analyze(context.Background(), nil, chanflow.Options{Dir: dir, Backend: backend})
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := filepath.Join(dir, "p", "p.go") + ":9"
+			for _, channel := range r.Channels {
+				for _, op := range channel.SendOps {
+					if op.Goroutine != want {
+						t.Errorf("send at %s runs on goroutine %q, want %q", op.Location, op.Goroutine, want)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package aliases
+
+// Closures use the channels they capture.
+func closure() {
+	ch := make(chan int, 1)
+	recv := func() int { return <-ch }
+	ch <- 1
+	recv()
+}
+
+func closureSendOnly() {
+	ch := make(chan int, 1) // want "channel ch has no receive operations"
+	send := func() { ch <- 1 }
+	send()
+}
+
+// Method values use the channels of their receiver.
+type box struct {
+	ch chan int
+}
+
+func (b *box) put() {
+	b.ch <- 1
+}
+
+func methodValue() {
+	b := &box{ch: make(chan int, 1)}
+	put := b.put
+	put()
+	<-b.ch
+}
+
+type outbox struct {
+	ch chan int // want "channel ch has no receive operations"
+}
+
+func (b *outbox) put() {
+	b.ch <- 1
+}
+
+func methodValueSendOnly() {
+	b := &outbox{ch: make(chan int, 1)}
+	put := b.put
+	put()
+}
+
+// Phi nodes merge the channels made on each path.
+func phi(cond bool) {
+	var ch chan int
+	if cond {
+		ch = make(chan int, 1)
+	} else {
+		ch = make(chan int, 2)
+	}
+	ch <- 1
+	<-ch
+}
+
+func phiSendOnly(cond bool) {
+	var ch chan int // want "channel ch has no receive operations"
+	if cond {
+		ch = make(chan int, 1)
+	} else {
+		ch = make(chan int, 2)
+	}
+	ch <- 1
+}
+
+// Results carry the channels made by the function to its callers.
+func newChan() chan int {
+	return make(chan int, 1)
+}
+
+func result() {
+	ch := newChan()
+	ch <- 1
+	<-ch
+}
+
+func newSendOnly() chan int { // want `channel newSendOnly\(\) has no receive operations`
+	return make(chan int, 1)
+}
+
+func resultSendOnly() {
+	ch := newSendOnly()
+	ch <- 1
+}
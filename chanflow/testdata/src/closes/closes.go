@@ -0,0 +1,165 @@
+package closes
+
+func doubleClose() {
+	ch := make(chan int, 1)
+	ch <- 1
+	close(ch)
+	<-ch
+	close(ch) // want `channel ch is closed again after the close at .*closes.go:6, which panics`
+}
+
+func sendAfterClose() {
+	ch := make(chan int, 1)
+	close(ch)
+	ch <- 1 // want `send on channel ch after the close at .*closes.go:13 panics`
+	<-ch
+}
+
+func closeOnce() {
+	ch := make(chan int, 1)
+	ch <- 1
+	<-ch
+	close(ch)
+}
+
+// Sends after closes on some paths only do not count.
+func conditionalClose(done bool) {
+	ch := make(chan int, 1)
+	if done {
+		close(ch)
+	}
+	ch <- 1
+	<-ch
+}
+
+// A close of another field, or of the same field of another value, is not
+// a close of the channel.
+type pair struct {
+	a, b chan int // want a:"channel a: 0 sends, 0 receives, 1 closes, 0 range loops" b:"channel b: 2 sends, 0 receives, 1 closes, 0 range loops"
+}
+
+func otherField(p *pair) {
+	close(p.a)
+	p.b <- 1
+}
+
+func otherValue(p, q *pair) {
+	close(p.b)
+	q.b <- 1
+}
+
+// A close of the channel held before an assignment does not close the new
+// one.
+func reassigned() {
+	ch := make(chan int, 1)
+	close(ch)
+	ch = make(chan int, 1)
+	ch <- 1
+	<-ch
+}
+
+// Elements of a slice may be different channels.
+func indexed(chs []chan int) { // want chs:"channel chs: 1 sends, 0 receives, 1 closes, 0 range loops"
+	close(chs[0])
+	chs[1] <- 1
+}
+
+// A close may run again in a loop, after a close on some paths, or before a
+// deferred close.
+func loopClose(n int) {
+	ch := make(chan int, 1)
+	ch <- 1
+	for i := 0; i < n; i++ {
+		close(ch) // want `channel ch may be closed again by this close, which runs in a loop, and panic`
+	}
+	<-ch
+}
+
+func maybeClosed(done bool) {
+	ch := make(chan int, 1)
+	ch <- 1
+	if done {
+		close(ch)
+	}
+	close(ch) // want `channel ch may be closed again after the close at .*closes.go:82, which panics`
+	<-ch
+}
+
+func deferredClose(done bool) {
+	ch := make(chan int, 1)
+	ch <- 1
+	defer close(ch) // want `channel ch may be closed again after the close at .*closes.go:93, which panics`
+	if done {
+		close(ch)
+	}
+	<-ch
+}
+
+// Closes on different branches, or of a channel made again in the loop,
+// never run one after the other.
+func branches(done bool) {
+	ch := make(chan int, 1)
+	ch <- 1
+	if done {
+		close(ch)
+	} else {
+		close(ch)
+	}
+	<-ch
+}
+
+func loopMake(n int) {
+	for i := 0; i < n; i++ {
+		ch := make(chan int, 1)
+		ch <- 1
+		close(ch)
+		<-ch
+	}
+}
+
+// Channels captured by a goroutine are closed and sent on through the same
+// variable.
+func capturedClose(done bool) {
+	ch := make(chan int)
+	go func() {
+		for range ch {
+		}
+	}()
+	ch <- 1
+	close(ch)
+	if done {
+		close(ch) // want `channel ch is closed again after the close at .*closes.go:129, which panics`
+	}
+}
+
+func capturedSend() {
+	ch := make(chan int)
+	go func() {
+		<-ch
+	}()
+	close(ch)
+	ch <- 1 // want `send on channel ch after the close at .*closes.go:140 panics`
+}
+
+func capturedReassigned() {
+	ch := make(chan int, 1)
+	go func() {
+		<-ch
+	}()
+	close(ch)
+	ch = make(chan int, 1)
+	ch <- 1
+	close(ch)
+}
+
+func capturedLoop(n int) {
+	for i := 0; i < n; i++ {
+		ch := make(chan int)
+		go func() {
+			for range ch {
+			}
+		}()
+		ch <- 1
+		close(ch)
+	}
+}
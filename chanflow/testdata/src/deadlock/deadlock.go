@@ -0,0 +1,93 @@
+package deadlock
+
+func sameGoroutine() {
+	ch := make(chan int)
+	ch <- 1 // want `send on unbuffered channel ch blocks forever: every receive \(.*deadlock.go:6\) runs on the same goroutine \(deadlock.sameGoroutine\)`
+	<-ch    // want `receive from unbuffered channel ch blocks forever: every send or close \(.*deadlock.go:5\) runs on the same goroutine \(deadlock.sameGoroutine\)`
+}
+
+func otherGoroutine() {
+	ch := make(chan int)
+	go func() {
+		ch <- 1
+	}()
+	<-ch
+}
+
+func buffered() {
+	ch := make(chan int, 1)
+	ch <- 1
+	<-ch
+}
+
+func selfTalk() {
+	ch := make(chan int)
+	go func() {
+		ch <- 1 // want `send on unbuffered channel ch blocks forever: every receive \(.*deadlock.go:27\) runs on the same goroutine \(goroutine started at .*deadlock.go:25\)`
+		<-ch    // want `receive from unbuffered channel ch blocks forever: every send or close \(.*deadlock.go:26\) runs on the same goroutine \(goroutine started at .*deadlock.go:25\)`
+	}()
+}
+
+// Goroutines started in a loop or from several go statements run as several
+// instances, which may hand values to each other.
+func player(table chan int) { // want table:"channel table: 1 sends, 1 receives, 0 closes, 0 range loops"
+	for {
+		ball := <-table
+		table <- ball + 1
+	}
+}
+
+func pingPong() {
+	table := make(chan int)
+	for i := 0; i < 2; i++ {
+		go player(table)
+	}
+	table <- 0
+}
+
+func loopLiteral() {
+	ch := make(chan int)
+	for i := 0; i < 2; i++ {
+		go func() {
+			ch <- 1
+			<-ch
+		}()
+	}
+}
+
+func relay(ch chan int) { // want ch:"channel ch: 1 sends, 1 receives, 0 closes, 0 range loops"
+	v := <-ch
+	ch <- v
+}
+
+func twoRelays() {
+	ch := make(chan int)
+	go relay(ch)
+	go relay(ch)
+	ch <- 1
+}
+
+// Functions called from goroutines run on each goroutine calling them.
+func relayOnce(ch chan int, first bool) { // want ch:"channel ch: 1 sends, 1 receives, 0 closes, 0 range loops"
+	if first {
+		ch <- 1
+	} else {
+		<-ch
+	}
+}
+
+func wrappedRelays() {
+	ch := make(chan int)
+	go func() { relayOnce(ch, true) }()
+	go func() { relayOnce(ch, false) }()
+}
+
+func sameCaller() {
+	ch := make(chan int)
+	helper(ch)
+}
+
+func helper(ch chan int) { // want ch:"channel ch: 1 sends, 1 receives, 0 closes, 0 range loops"
+	ch <- 1 // want `send on unbuffered channel ch blocks forever`
+	<-ch    // want `receive from unbuffered channel ch blocks forever`
+}
@@ -0,0 +1,11 @@
+package lib
+
+var Done = make(chan struct{}) // want Done:"channel Done: 0 sends, 1 receives, 1 closes, 0 range loops"
+
+func Wait() {
+	<-Done
+}
+
+func Stop() {
+	close(Done)
+}
@@ -0,0 +1,8 @@
+package use
+
+import "facts/lib"
+
+// The close in lib counts along with this one.
+func stop() {
+	close(lib.Done) // want `channel Done is closed by more than one party \(lib.Stop, use.stop\); it may be closed twice`
+}
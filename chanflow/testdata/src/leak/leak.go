@@ -0,0 +1,107 @@
+package leak
+
+import "time"
+
+func timeout() int {
+	ch := make(chan int)
+	go func() {
+		ch <- 1 // want `goroutine started at .*leak.go:7 may leak: send on unbuffered channel ch blocks forever when the select \(.*leak.go:11\) waiting for its receive times out or is cancelled`
+	}()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(time.Second):
+		return 0
+	}
+}
+
+func wait() int {
+	ch := make(chan int)
+	go func() {
+		ch <- 1
+	}()
+	select {
+	case v := <-ch:
+		return v
+	}
+}
+
+func bufferedTimeout() int {
+	ch := make(chan int, 1)
+	go func() {
+		ch <- 1
+	}()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(time.Second):
+		return 0
+	}
+}
+
+// A goroutine whose only counterpart is skipped by an early return of the
+// function starting it stays blocked.
+func compute() (int, error) { return 1, nil }
+
+func earlyReturn() int {
+	ch := make(chan int)
+	go func() {
+		v, _ := compute()
+		ch <- v // want `goroutine started at .*leak.go:48 may leak: send on unbuffered channel ch blocks forever when leak.earlyReturn returns before its receive \(.*leak.go:55\)`
+	}()
+	if _, err := compute(); err != nil {
+		return 0
+	}
+	return <-ch
+}
+
+func receiveOnEveryPath(fail bool) int {
+	ch := make(chan int)
+	go func() {
+		ch <- 1
+	}()
+	if fail {
+		<-ch
+		return 0
+	}
+	return <-ch
+}
+
+func panicBefore(fail bool) int {
+	ch := make(chan int)
+	go func() {
+		ch <- 1
+	}()
+	if fail {
+		panic("fail")
+	}
+	return <-ch
+}
+
+// Goroutines started in a loop and collected by another are not taken to
+// be skipped when the loops may run no iteration.
+func fanIn(xs []int) int {
+	results := make(chan int)
+	for _, x := range xs {
+		go func() {
+			results <- x
+		}()
+	}
+	sum := 0
+	for range xs {
+		sum += <-results
+	}
+	return sum
+}
+
+func collectInLoop(n int) int {
+	results := make(chan int)
+	go func() {
+		results <- n
+	}()
+	sum := 0
+	for i := 0; i < 1; i++ {
+		sum += <-results
+	}
+	return sum
+}
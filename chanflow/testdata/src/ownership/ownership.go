@@ -0,0 +1,43 @@
+package ownership
+
+func nonOwnerClose() {
+	ch := make(chan int)
+	go func() {
+		ch <- 1
+	}()
+	<-ch
+	close(ch) // want "channel ch is closed by ownership.nonOwnerClose, which only receives from it; only a sender should close a channel"
+}
+
+func ownerClose() {
+	ch := make(chan int)
+	go func() {
+		ch <- 1
+		close(ch)
+	}()
+	for range ch {
+	}
+}
+
+func multipleClosers() {
+	ch := make(chan int)
+	go func() {
+		ch <- 1
+		close(ch) // want `channel ch is closed by more than one party \(goroutine started at .*ownership.go:24, goroutine started at .*ownership.go:28\); it may be closed twice`
+	}()
+	go func() {
+		ch <- 2
+		close(ch) // want `channel ch is closed by more than one party \(goroutine started at .*ownership.go:24, goroutine started at .*ownership.go:28\); it may be closed twice`
+	}()
+	<-ch
+}
+
+func loopedCloser(n int) {
+	done := make(chan struct{})
+	for i := 0; i < n; i++ {
+		go func() {
+			close(done) // want `channel done is closed by more than one party \(goroutine started at .*ownership.go:38 \(several instances\)\); it may be closed twice`
+		}()
+	}
+	<-done
+}
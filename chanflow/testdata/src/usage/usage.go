@@ -0,0 +1,87 @@
+package usage
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"time"
+)
+
+func dangling() {
+	ch := make(chan int) // want "channel ch has no send, receive, close or range operations"
+	_ = ch
+}
+
+func sendOnly() {
+	ch := make(chan int, 1) // want "channel ch has no receive operations"
+	ch <- 1
+}
+
+func receiveOnly() {
+	ch := make(chan int, 1) // want "channel ch has no send or close operations"
+	<-ch
+}
+
+func used() {
+	ch := make(chan int, 1)
+	ch <- 1
+	<-ch
+}
+
+func closedAndRanged() {
+	ch := make(chan int, 1)
+	ch <- 1
+	close(ch)
+	for range ch {
+	}
+}
+
+// Channels that never got a make site in the analyzed code come from
+// callers, so their other side is out of sight.
+func param(ch chan int) { // want ch:"channel ch: 1 sends, 0 receives, 0 closes, 0 range loops"
+	ch <- 1
+}
+
+func sendParam(ch chan<- int) { // want ch:"channel ch: 1 sends, 0 receives, 0 closes, 0 range loops"
+	ch <- 1
+}
+
+func recvParam(ch <-chan int) int { // want ch:"channel ch: 0 sends, 1 receives, 0 closes, 0 range loops"
+	return <-ch
+}
+
+type T struct {
+	ch chan int // want ch:"channel ch: 1 sends, 0 receives, 0 closes, 0 range loops"
+}
+
+func (t *T) send() {
+	t.ch <- 1
+}
+
+// Channels passed to functions outside the analyzed code may be used on the
+// other side there, and channels obtained from such code without a make
+// here are as well.
+func notify() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt)
+	<-c
+}
+
+func done(ctx context.Context) {
+	d := ctx.Done()
+	<-d
+}
+
+func timer(t *time.Timer) {
+	c := t.C
+	<-c
+}
+
+// A function the channel is followed into counts with its operations.
+func keep(ch chan int) {} // want ch:"channel ch: 0 sends, 0 receives, 0 closes, 0 range loops"
+
+func passed() {
+	ch := make(chan int, 1) // want "channel ch has no receive operations"
+	ch <- 1
+	keep(ch)
+}
@@ -0,0 +1,15 @@
+// Command chanflow runs the channel checks of channeling as a vet tool:
+//
+//	chanflow ./...
+//	go vet -vettool=$(which chanflow) ./...
+package main
+
+import (
+	"channeling/chanflow"
+
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(chanflow.Analyzer)
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"strings"
+
+	"channeling/chanflow"
+
+	"github.com/spf13/cobra"
+)
+
+// loadFlags are the flags selecting what is analyzed and how, shared by
+// every subcommand.
+type loadFlags struct {
+	backend    string
+	dir        string
+	tags       []string
+	tests      bool
+	generated  bool
+	exclude    []string
+	config     string
+	jobs       int
+	progress   bool
+	noCache    bool
+	severities map[string]string
+}
+
+func (f *loadFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.backend, "backend", chanflow.BackendAST,
+		"analysis backend: ast (syntax matching) or ssa (SSA value flow with a VTA call graph)")
+	cmd.Flags().StringVarP(&f.dir, "dir", "C", "", "directory to resolve package patterns in (default: current directory)")
+	cmd.Flags().StringSliceVar(&f.tags, "tags", nil, "comma-separated build tags to select files with")
+	cmd.Flags().BoolVar(&f.tests, "tests", false, "include _test.go files and external test packages")
+	cmd.Flags().BoolVar(&f.generated, "generated", false, `include generated files, marked with a "Code generated ... DO NOT EDIT." comment`)
+	cmd.Flags().StringSliceVar(&f.exclude, "exclude", nil,
+		`patterns of files to leave out, as in "gen" or "*_mock.go" (replaces those of the config file)`)
+	cmd.Flags().StringVar(&f.config, "config", "", "configuration file (default: "+configFile+" in the analyzed directory or a parent)")
+	cmd.Flags().IntVarP(&f.jobs, "jobs", "j", runtime.GOMAXPROCS(0), "number of files to analyze concurrently")
+	cmd.Flags().BoolVar(&f.progress, "progress", isTerminal(os.Stderr), "print progress to stderr (default: when stderr is a terminal)")
+	cmd.Flags().BoolVar(&f.noCache, "no-cache", false, "analyze every package again instead of reusing cached results")
+}
+
+// configure reads the configuration file into the flags of cmd that were
+// not given on the command line and returns it, or nil if there is none.
+// Rule severities of the file are overridden one by one by --severity.
+func (f *loadFlags) configure(cmd *cobra.Command) (*config, error) {
+	name := f.config
+	if name == "" {
+		var err error
+		name, err = findConfig(f.root())
+		if err != nil || name == "" {
+			return nil, err
+		}
+	}
+	cfg, err := readConfig(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := setDefault(cmd, "backend", cfg.Backend); err != nil {
+		return nil, err
+	}
+	if !cmd.Flags().Changed("tags") && cfg.Tags != nil {
+		f.tags = cfg.Tags
+	}
+	if !cmd.Flags().Changed("tests") {
+		f.tests = cfg.Tests
+	}
+	if !cmd.Flags().Changed("generated") {
+		f.generated = cfg.Generated
+	}
+	if !cmd.Flags().Changed("exclude") {
+		f.exclude = cfg.exclude()
+	}
+	if !cmd.Flags().Changed("jobs") && cfg.Workers > 0 {
+		f.jobs = cfg.Workers
+	}
+
+	severities := make(map[string]string)
+	for rule, severity := range cfg.Rules {
+		severities[rule] = severity
+	}
+	for rule, severity := range f.severities {
+		severities[rule] = severity
+	}
+	f.severities = severities
+	return cfg, nil
+}
+
+// root returns the directory patterns are resolved in.
+func (f *loadFlags) root() string {
+	if f.dir == "" {
+		return "."
+	}
+	return f.dir
+}
+
+// load analyzes the packages matching patterns. Package errors are printed
+// to stderr unless quiet, for formats that carry them in the output.
+func (f *loadFlags) load(ctx context.Context, patterns []string, quiet bool) (*chanflow.Report, error) {
+	if f.jobs < 1 {
+		return nil, fmt.Errorf("--jobs must be at least 1")
+	}
+	opts := chanflow.Options{
+		Dir:        f.dir,
+		Backend:    f.backend,
+		Tags:       f.tags,
+		Tests:      f.tests,
+		Generated:  f.generated,
+		Severities: f.severities,
+		Exclude:    f.exclude,
+		Workers:    f.jobs,
+	}
+	if !f.noCache {
+		// Without a cache directory every run starts from scratch.
+		opts.CacheDir, _ = chanflow.DefaultCacheDir()
+	}
+	if f.progress {
+		var finish func()
+		opts.Progress, finish = startProgress(os.Stderr)
+		defer finish()
+	}
+	report, err := chanflow.Analyze(ctx, patterns, opts)
+	if err != nil {
+		if len(patterns) == 0 {
+			patterns = []string{"./..."}
+		}
+		return nil, fmt.Errorf("analyzing %s: %w", strings.Join(patterns, " "), err)
+	}
+	if !quiet {
+		for _, e := range report.PackageErrors {
+			fmt.Fprintf(os.Stderr, "Error in package %s: %s\n", e.Package, e.Message)
+		}
+	}
+	return report, nil
+}
+
+// exitError ends the program with exit status code after printing
+// message to stderr.
+type exitError struct {
+	code    int
+	message string
+}
+
+func (e *exitError) Error() string {
+	return e.message
+}
+
+func newAnalyzeCmd() *cobra.Command {
+	var flags loadFlags
+	var format string
+	cmd := &cobra.Command{
+		Use:   "analyze [packages]",
+		Short: "Print the channels of Go packages with their operations and diagnostics",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := flags.configure(cmd)
+			if err != nil {
+				return err
+			}
+			if cfg != nil {
+				if err := setDefault(cmd, "format", cfg.Analyze.Format); err != nil {
+					return err
+				}
+			}
+			if format != "text" && format != "json" && format != "sarif" {
+				return fmt.Errorf("unknown format %q: use text, json or sarif", format)
+			}
+			// Package errors are part of the JSON report; stdout stays pure
+			// JSON.
+			report, err := flags.load(cmd.Context(), args, format == "json")
+			if err != nil {
+				return err
+			}
+			switch format {
+			case "json":
+				return writeJSONReport(os.Stdout, report)
+			case "sarif":
+				return writeSARIF(os.Stdout, report, flags.root())
+			}
+			printChannelInfo(report)
+			return nil
+		},
+	}
+	flags.register(cmd)
+	cmd.Flags().StringVar(&format, "format", "text",
+		"output format: text, json (versioned report) or sarif (SARIF 2.1.0 diagnostics)")
+	return cmd
+}
+
+func newGraphCmd() *cobra.Command {
+	var flags loadFlags
+	var output string
+	cmd := &cobra.Command{
+		Use:   "graph [packages]",
+		Short: "Write the channel flow graph of Go packages in Graphviz DOT format",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := flags.configure(cmd)
+			if err != nil {
+				return err
+			}
+			if cfg != nil {
+				if err := setDefault(cmd, "output", cfg.Graph.Output); err != nil {
+					return err
+				}
+			}
+			report, err := flags.load(cmd.Context(), args, false)
+			if err != nil {
+				return err
+			}
+			return visualizeChannels(report, output)
+		},
+	}
+	flags.register(cmd)
+	cmd.Flags().StringVarP(&output, "output", "o", "channel_flow.dot", `DOT file to write, or "-" for stdout`)
+	return cmd
+}
+
+func newServeCmd() *cobra.Command {
+	var flags loadFlags
+	var addr string
+	var watch bool
+	cmd := &cobra.Command{
+		Use:   "serve [packages]",
+		Short: "Serve an interactive visualization of the channels of Go packages",
+		Long: `Serve an interactive visualization of the channels of Go packages.
+
+With --watch, the Go files under the analyzed directory are checked for changes
+every second; the packages are then analyzed again and open pages redrawn.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := flags.configure(cmd)
+			if err != nil {
+				return err
+			}
+			if cfg != nil {
+				if err := setDefault(cmd, "addr", cfg.Serve.Addr); err != nil {
+					return err
+				}
+				if !cmd.Flags().Changed("watch") {
+					watch = cfg.Serve.Watch
+				}
+			}
+			ctx := cmd.Context()
+			report, err := flags.load(ctx, args, false)
+			if err != nil {
+				return err
+			}
+			feed := newGraphFeed(generateWebGraph(report))
+			if watch {
+				go watchTree(ctx, flags.root(), watchInterval, func() {
+					fmt.Fprintln(os.Stderr, "Files changed, analyzing again")
+					report, err := flags.load(ctx, args, false)
+					if err != nil {
+						// The pages keep the last graph until the
+						// packages can be analyzed again.
+						if ctx.Err() == nil {
+							fmt.Fprintln(os.Stderr, err)
+						}
+						return
+					}
+					feed.publish(generateWebGraph(report))
+				})
+			}
+			return startWebServer(ctx, feed, addr, watch)
+		},
+	}
+	flags.register(cmd)
+	cmd.Flags().StringVar(&addr, "addr", ":8080", "address to listen on")
+	cmd.Flags().BoolVar(&watch, "watch", false, "analyze again when files change and update open pages")
+	return cmd
+}
+
+func newLintCmd() *cobra.Command {
+	var flags loadFlags
+	var format, failOn string
+	cmd := &cobra.Command{
+		Use:   "lint [packages]",
+		Short: "Report the diagnostics of Go packages",
+		Long: `Report the diagnostics of Go packages.
+
+Lint exits with status 2 when the analysis fails or a package cannot be loaded
+or type-checked, with status 1 when a diagnostic is at least as severe as
+--fail-on, and with status 0 otherwise.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := flags.configure(cmd)
+			if err != nil {
+				return err
+			}
+			if cfg != nil {
+				if err := setDefault(cmd, "format", cfg.Lint.Format); err != nil {
+					return err
+				}
+				if err := setDefault(cmd, "fail-on", cfg.Lint.FailOn); err != nil {
+					return err
+				}
+			}
+			if format != "text" && format != "sarif" {
+				return fmt.Errorf("unknown format %q: use text or sarif", format)
+			}
+			threshold := chanflow.SeverityRank(failOn)
+			if failOn == "none" {
+				threshold = math.MaxInt
+			} else if threshold < 1 {
+				return fmt.Errorf("unknown --fail-on level %q: use error, warning, note or none", failOn)
+			}
+
+			report, err := flags.load(cmd.Context(), args, false)
+			if err != nil {
+				return err
+			}
+			if format == "sarif" {
+				if err := writeSARIF(os.Stdout, report, flags.root()); err != nil {
+					return err
+				}
+			} else {
+				for _, d := range report.Diagnostics {
+					fmt.Printf("%s: %s: %s [%s]\n", d.Position, d.Severity, d.Message, d.Rule)
+				}
+			}
+
+			// A mistyped pattern or a package that does not build must not
+			// pass as clean.
+			if n := len(report.PackageErrors); n > 0 {
+				return &exitError{code: 2, message: fmt.Sprintf("%d package error(s)", n)}
+			}
+			failed := 0
+			for _, d := range report.Diagnostics {
+				if chanflow.SeverityRank(d.Severity) >= threshold {
+					failed++
+				}
+			}
+			if failed > 0 {
+				return &exitError{code: 1, message: fmt.Sprintf("%d diagnostic(s) at or above %s", failed, failOn)}
+			}
+			return nil
+		},
+	}
+	flags.register(cmd)
+	cmd.Flags().StringVar(&format, "format", "text", "output format: text or sarif (SARIF 2.1.0)")
+	cmd.Flags().StringVar(&failOn, "fail-on", chanflow.SeverityError,
+		"lowest severity that makes lint exit with status 1: error, warning, note or none")
+	cmd.Flags().StringToStringVar(&flags.severities, "severity", nil,
+		"override rule severities, as in deadlock=warning,dangling=off (error, warning, note or off)")
+	return cmd
+}
+
+func newCacheCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Manage the cache of analysis results",
+		Long: `Manage the cache of analysis results.
+
+The results of the ast backend are cached package by package in ` + "`channeling`" + ` under
+the user cache directory, so that later runs only analyze the packages whose
+files or dependencies changed.`,
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "clean",
+		Short: "Remove every cached analysis result",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir, err := chanflow.DefaultCacheDir()
+			if err != nil {
+				return err
+			}
+			if err := chanflow.CleanCache(dir); err != nil {
+				return err
+			}
+			fmt.Printf("Removed %s\n", dir)
+			return nil
+		},
+	})
+	return cmd
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// configFile is the name of the project configuration file, looked up in the
+// analyzed directory and its parents.
+const configFile = ".channeling.json"
+
+// config is the content of a configuration file. Every setting is the
+// default of the flag of the same name, which overrides it.
+type config struct {
+	Backend   string   `json:"backend"`
+	Tags      []string `json:"tags"`
+	Tests     bool     `json:"tests"`
+	Generated bool     `json:"generated"`
+	Workers   int      `json:"workers"`
+	// Exclude lists patterns of files left out of the analysis. Patterns
+	// with a slash are relative to the directory of the configuration file.
+	Exclude []string `json:"exclude"`
+	// Rules sets the severity of rules by ID; "off" disables a rule.
+	Rules map[string]string `json:"rules"`
+
+	Analyze struct {
+		Format string `json:"format"`
+	} `json:"analyze"`
+	Lint struct {
+		Format string `json:"format"`
+		FailOn string `json:"failOn"`
+	} `json:"lint"`
+	Graph struct {
+		Output string `json:"output"`
+	} `json:"graph"`
+	Serve struct {
+		Addr  string `json:"addr"`
+		Watch bool   `json:"watch"`
+	} `json:"serve"`
+
+	// dir is the directory the file was found in.
+	dir string
+}
+
+// findConfig returns the path of the configuration file of dir, the nearest
+// one in dir or its parents, or "" if there is none.
+func findConfig(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		name := filepath.Join(dir, configFile)
+		if _, err := os.Stat(name); err == nil {
+			return name, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// readConfig parses the configuration file name. Unknown settings are
+// rejected so that typos do not go unnoticed.
+func readConfig(name string) (*config, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &config{dir: filepath.Dir(name)}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if cfg.Workers < 0 {
+		return nil, fmt.Errorf("%s: workers must not be negative", name)
+	}
+	return cfg, nil
+}
+
+// exclude returns the exclude patterns of cfg, anchored to its directory.
+func (cfg *config) exclude() []string {
+	var patterns []string
+	for _, pattern := range cfg.Exclude {
+		pattern = filepath.ToSlash(pattern)
+		if path.IsAbs(pattern) || !strings.Contains(pattern, "/") {
+			patterns = append(patterns, pattern)
+			continue
+		}
+		patterns = append(patterns, path.Join(filepath.ToSlash(cfg.dir), pattern))
+	}
+	return patterns
+}
+
+// setDefault sets the flag name of cmd to value unless it was given on the
+// command line or value is empty.
+func setDefault(cmd *cobra.Command, name, value string) error {
+	if value == "" || cmd.Flags().Changed(name) {
+		return nil
+	}
+	return cmd.Flags().Set(name, value)
+}
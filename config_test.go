@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestFindConfig(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(nested, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// Without a file in root, the lookup goes on above it.
+	above, err := findConfig(filepath.Dir(root))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := findConfig(nested); err != nil || got != above {
+		t.Errorf("findConfig(%q) = %q, %v; want %q", nested, got, err, above)
+	}
+
+	name := filepath.Join(root, configFile)
+	if err := os.WriteFile(name, []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{root, filepath.Join(root, "a"), nested} {
+		if got, err := findConfig(dir); err != nil || got != name {
+			t.Errorf("findConfig(%q) = %q, %v; want %q", dir, got, err, name)
+		}
+	}
+
+	inner := filepath.Join(root, "a", configFile)
+	if err := os.WriteFile(inner, []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := findConfig(nested); err != nil || got != inner {
+		t.Errorf("findConfig(%q) = %q, %v; want the nearest, %q", nested, got, err, inner)
+	}
+}
+
+func TestConfigExclude(t *testing.T) {
+	cfg := &config{dir: "/project"}
+	for _, tt := range []struct {
+		pattern string
+		want    string
+	}{
+		{"gen", "gen"},
+		{"*_mock.go", "*_mock.go"},
+		{"internal/gen", "/project/internal/gen"},
+		{"internal/gen/", "/project/internal/gen"},
+		{"./api/*.pb.go", "/project/api/*.pb.go"},
+		{"../shared/gen", "/shared/gen"},
+		{"/abs/gen", "/abs/gen"},
+	} {
+		cfg.Exclude = []string{tt.pattern}
+		if got := cfg.exclude(); len(got) != 1 || got[0] != tt.want {
+			t.Errorf("exclude of %q = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestConfigureExclude(t *testing.T) {
+	dir := t.TempDir()
+	config := `{"exclude": ["gen", "internal/mock"]}`
+	if err := os.WriteFile(filepath.Join(dir, configFile), []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		args []string
+		want []string
+	}{
+		{nil, []string{"gen", filepath.ToSlash(filepath.Join(dir, "internal", "mock"))}},
+		// The flag replaces the patterns of the file, relative to -C.
+		{[]string{"--exclude", "other,*_test.go"}, []string{"other", "*_test.go"}},
+	} {
+		cmd := &cobra.Command{Use: "test"}
+		var f loadFlags
+		f.register(cmd)
+		if err := cmd.Flags().Parse(append([]string{"-C", dir}, tt.args...)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.configure(cmd); err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(f.exclude, tt.want) {
+			t.Errorf("args %q: exclude = %q, want %q", tt.args, f.exclude, tt.want)
+		}
+	}
+}
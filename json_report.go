@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"go/token"
+	"io"
+
+	"channeling/chanflow"
+)
+
+// jsonSchemaVersion is the version of the JSON report written by
+// writeJSONReport. It changes whenever a field is renamed, removed or
+// changes meaning; new fields may be added without a bump.
+const jsonSchemaVersion = 1
+
+type jsonReport struct {
+	SchemaVersion int                `json:"schemaVersion"`
+	Tool          string             `json:"tool"`
+	Channels      []jsonChannel      `json:"channels"`
+	Goroutines    []jsonGoroutine    `json:"goroutines"`
+	Diagnostics   []jsonDiagnostic   `json:"diagnostics"`
+	PackageErrors []jsonPackageError `json:"packageErrors"`
+}
+
+type jsonPosition struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column,omitempty"`
+}
+
+type jsonChannel struct {
+	ID          string          `json:"id"`
+	Name        string          `json:"name"`
+	Kind        string          `json:"kind"`
+	Type        string          `json:"type"`
+	ElemType    string          `json:"elemType"`
+	Direction   string          `json:"direction"`
+	Capacities  []string        `json:"capacities"`
+	Declaration jsonPosition    `json:"declaration"`
+	Aliases     []string        `json:"aliases"`
+	Owner       string          `json:"owner,omitempty"`
+	Parties     []jsonParty     `json:"parties"`
+	Operations  []jsonOperation `json:"operations"`
+	Files       []string        `json:"files"`
+}
+
+type jsonParty struct {
+	Name  string   `json:"name"`
+	Roles []string `json:"roles"`
+}
+
+// jsonOperation is a single use of a channel. Kind is one of make, send,
+// receive, close, range, return and pass, and Goroutine the position of the
+// go statement starting the goroutine running it, if any. MayBeClosed marks
+// a ClosedAt that only runs before the operation on some paths.
+type jsonOperation struct {
+	Kind            string        `json:"kind"`
+	Position        jsonPosition  `json:"position"`
+	Func            string        `json:"func"`
+	Goroutine       *jsonPosition `json:"goroutine,omitempty"`
+	Select          bool          `json:"select,omitempty"`
+	SelectCanGiveUp bool          `json:"selectCanGiveUp,omitempty"`
+	ClosedAt        *jsonPosition `json:"closedAt,omitempty"`
+	MayBeClosed     bool          `json:"mayBeClosed,omitempty"`
+}
+
+type jsonGoroutine struct {
+	Site     jsonPosition `json:"site"`
+	Funcs    []string     `json:"funcs"`
+	Channels []string     `json:"channels"`
+}
+
+type jsonDiagnostic struct {
+	Rule     string         `json:"rule"`
+	Severity string         `json:"severity"`
+	Channel  string         `json:"channel"`
+	Position jsonPosition   `json:"position"`
+	Message  string         `json:"message"`
+	Related  []jsonPosition `json:"related"`
+}
+
+type jsonPackageError struct {
+	Package string `json:"package"`
+	Message string `json:"message"`
+}
+
+// writeJSONReport writes report to w as an indented JSON document in the
+// jsonSchemaVersion schema. Lists are always present, empty rather than
+// null.
+func writeJSONReport(w io.Writer, report *chanflow.Report) error {
+	out := jsonReport{
+		SchemaVersion: jsonSchemaVersion,
+		Tool:          "channeling",
+		Channels:      []jsonChannel{},
+		Goroutines:    []jsonGoroutine{},
+		Diagnostics:   []jsonDiagnostic{},
+		PackageErrors: []jsonPackageError{},
+	}
+	for _, channel := range report.Channels {
+		c := jsonChannel{
+			ID:          channel.ID,
+			Name:        channel.Name,
+			Kind:        channel.Kind,
+			Type:        channel.Type,
+			ElemType:    channel.ElemType,
+			Direction:   channel.Direction,
+			Capacities:  nonNil(channel.Capacities),
+			Declaration: positionOf(channel.Position),
+			Aliases:     nonNil(channel.Aliases),
+			Owner:       channel.Owner,
+			Parties:     []jsonParty{},
+			Operations:  []jsonOperation{},
+			Files:       nonNil(channel.UsedInFiles),
+		}
+		for _, p := range channel.Parties {
+			c.Parties = append(c.Parties, jsonParty{Name: p.Name, Roles: nonNil(p.Roles())})
+		}
+		for _, group := range []struct {
+			kind string
+			ops  []chanflow.Operation
+		}{
+			{"make", channel.MakeOps},
+			{"send", channel.SendOps},
+			{"receive", channel.ReceiveOps},
+			{"close", channel.CloseOps},
+			{"range", channel.RangeOps},
+			{"return", channel.ReturnedFrom},
+			{"pass", channel.PassedTo},
+		} {
+			for _, op := range group.ops {
+				o := jsonOperation{
+					Kind:            group.kind,
+					Position:        positionOf(op.Position),
+					Func:            op.Func,
+					Select:          op.Select,
+					SelectCanGiveUp: op.SelectCanGiveUp,
+				}
+				if op.GoroutinePosition.IsValid() {
+					goroutine := positionOf(op.GoroutinePosition)
+					o.Goroutine = &goroutine
+				}
+				if op.ClosedAt.IsValid() {
+					closedAt := positionOf(op.ClosedAt)
+					o.ClosedAt = &closedAt
+					o.MayBeClosed = op.MayBeClosed
+				}
+				c.Operations = append(c.Operations, o)
+			}
+		}
+		out.Channels = append(out.Channels, c)
+	}
+	for _, g := range report.Goroutines {
+		out.Goroutines = append(out.Goroutines, jsonGoroutine{
+			Site:     positionOf(g.Position),
+			Funcs:    nonNil(g.Funcs),
+			Channels: nonNil(g.Channels),
+		})
+	}
+	for _, d := range report.Diagnostics {
+		diag := jsonDiagnostic{
+			Rule:     d.Rule,
+			Severity: d.Severity,
+			Channel:  d.Channel,
+			Position: positionOf(d.Position),
+			Message:  d.Message,
+			Related:  []jsonPosition{},
+		}
+		for _, related := range d.Related {
+			diag.Related = append(diag.Related, positionOf(related))
+		}
+		out.Diagnostics = append(out.Diagnostics, diag)
+	}
+	for _, e := range report.PackageErrors {
+		out.PackageErrors = append(out.PackageErrors, jsonPackageError{Package: e.Package, Message: e.Message})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+func positionOf(pos token.Position) jsonPosition {
+	return jsonPosition{File: pos.Filename, Line: pos.Line, Column: pos.Column}
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
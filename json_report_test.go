@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"channeling/chanflow"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of the tests")
+
+// TestJSONReport compares the JSON report of a small module with
+// testdata/report.json, with the paths made relative to the module. Run
+// go test -update to rewrite it after changing the schema.
+func TestJSONReport(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod": "module example.com/p\n\ngo 1.22\n",
+		"p.go": `package p
+
+func Run() int {
+	ch := make(chan int)
+	go func() {
+		ch <- 1
+		close(ch)
+	}()
+	return <-ch
+}
+
+func Twice() {
+	done := make(chan struct{})
+	close(done)
+	close(done)
+}
+`,
+	})
+	report, err := chanflow.Analyze(context.Background(), nil, chanflow.Options{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := writeJSONReport(&buf, report); err != nil {
+		t.Fatal(err)
+	}
+	got := bytes.ReplaceAll(buf.Bytes(), []byte(dir+string(filepath.Separator)), nil)
+
+	golden := filepath.Join("testdata", "report.json")
+	if *update {
+		if err := os.WriteFile(golden, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("JSON report:\n%s\nwant:\n%s", got, want)
+	}
+}
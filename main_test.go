@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestLintExitStatus(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		source string
+		args   []string
+		want   int
+		stderr string
+	}{
+		{"clean", `package p
+
+func Run() int {
+	ch := make(chan int, 1)
+	ch <- 1
+	return <-ch
+}
+`, nil, 0, ""},
+		{"warning", `package p
+
+func Run() {
+	ch := make(chan int, 1)
+	ch <- 1
+}
+`, nil, 0, ""},
+		{"warning at fail-on warning", `package p
+
+func Run() {
+	ch := make(chan int, 1)
+	ch <- 1
+}
+`, []string{"--fail-on", "warning"}, 1, "1 diagnostic(s) at or above warning"},
+		{"error", `package p
+
+func Run() int {
+	ch := make(chan int, 1)
+	ch <- 1
+	close(ch)
+	close(ch)
+	return <-ch
+}
+`, nil, 1, "1 diagnostic(s) at or above error"},
+		{"package error", `package p
+
+func Run() {
+	ch := make(chan int, 1)
+	ch <- undefined
+}
+`, nil, 2, "1 package error(s)"},
+		{"bad flag", `package p
+`, []string{"--format", "xml"}, 2, `unknown format "xml"`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeModule(t, map[string]string{
+				"go.mod": "module example.com/p\n\ngo 1.22\n",
+				"p.go":   tt.source,
+			})
+
+			cmd := newRootCmd()
+			cmd.SetArgs(append([]string{"lint", "-C", dir, "--no-cache", "--progress=false"}, tt.args...))
+			got := 0
+			var stderr bytes.Buffer
+			if err := cmd.ExecuteContext(context.Background()); err != nil {
+				got = exitStatus(err, &stderr)
+			}
+			if got != tt.want {
+				t.Errorf("exit status %d, want %d (stderr: %q)", got, tt.want, stderr.String())
+			}
+			if !strings.Contains(stderr.String(), tt.stderr) {
+				t.Errorf("stderr %q, want %q", stderr.String(), tt.stderr)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"channeling/chanflow"
+)
+
+// progressInterval is how often the progress line is redrawn on a terminal;
+// other outputs get a new line at most every progressLogInterval.
+const (
+	progressInterval    = 200 * time.Millisecond
+	progressLogInterval = 2 * time.Second
+)
+
+// progressPrinter prints the progress of an analysis to w: a status line
+// redrawn in place on a terminal, one line at a time otherwise.
+type progressPrinter struct {
+	w        io.Writer
+	terminal bool
+
+	mu      sync.Mutex
+	last    chanflow.Progress
+	printed string
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// startProgress starts printing progress to w. Report is the callback for
+// chanflow.Options.Progress; finish prints the last status and stops.
+func startProgress(w *os.File) (report func(chanflow.Progress), finish func()) {
+	p := &progressPrinter{
+		w:        w,
+		terminal: isTerminal(w),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	interval := progressLogInterval
+	if p.terminal {
+		interval = progressInterval
+	}
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.print()
+			case <-p.stop:
+				p.print()
+				if p.terminal && p.printed != "" {
+					fmt.Fprintln(p.w)
+				}
+				return
+			}
+		}
+	}()
+	report = func(progress chanflow.Progress) {
+		p.mu.Lock()
+		p.last = progress
+		p.mu.Unlock()
+	}
+	var once sync.Once
+	finish = func() {
+		once.Do(func() {
+			close(p.stop)
+			<-p.done
+		})
+	}
+	return report, finish
+}
+
+// print writes the latest progress unless it is unchanged.
+func (p *progressPrinter) print() {
+	p.mu.Lock()
+	line := progressLine(p.last)
+	p.mu.Unlock()
+	if line == "" || line == p.printed {
+		return
+	}
+	p.printed = line
+	if p.terminal {
+		fmt.Fprintf(p.w, "\r\033[K%s", line)
+	} else {
+		fmt.Fprintln(p.w, line)
+	}
+}
+
+func progressLine(p chanflow.Progress) string {
+	elapsed := p.Elapsed.Round(100 * time.Millisecond)
+	switch p.Stage {
+	case chanflow.StageLoad:
+		return fmt.Sprintf("loading: %d files parsed (%s)", p.FilesParsed, elapsed)
+	case chanflow.StageAnalyze:
+		if p.Files == 0 {
+			return fmt.Sprintf("analyzing: %d files parsed, %d packages type-checked%s (%s)",
+				p.FilesParsed, p.Packages, cached(p), elapsed)
+		}
+		return fmt.Sprintf("analyzing: %d/%d files, %d packages type-checked%s (%s)",
+			p.FilesAnalyzed, p.Files, p.Packages, cached(p), elapsed)
+	case chanflow.StageDone:
+		return fmt.Sprintf("done: %d files parsed, %d packages type-checked%s (%s)",
+			p.FilesParsed, p.Packages, cached(p), elapsed)
+	}
+	return ""
+}
+
+func cached(p chanflow.Progress) string {
+	if p.Cached == 0 {
+		return ""
+	}
+	return fmt.Sprintf(", %d cached", p.Cached)
+}
+
+// isTerminal reports whether f is a character device such as a terminal.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"channeling/chanflow"
+)
+
+func TestSARIFRelatedLocations(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod": "module example.com/p\n\ngo 1.22\n",
+		"p.go": `package p
+
+func Run() int {
+	ch := make(chan int, 1)
+	ch <- 1
+	close(ch)
+	close(ch)
+	return <-ch
+}
+`,
+	})
+	report, err := chanflow.Analyze(context.Background(), nil, chanflow.Options{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := writeSARIF(&buf, report, dir); err != nil {
+		t.Fatal(err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+
+	var related []sarifRegion
+	for _, result := range log.Runs[0].Results {
+		if result.RuleID != chanflow.RuleDoubleClose {
+			continue
+		}
+		for _, loc := range result.RelatedLocations {
+			related = append(related, loc.PhysicalLocation.Region)
+		}
+	}
+	// The second close refers to the first one, with its column.
+	want := []sarifRegion{{StartLine: 6, StartColumn: 2}}
+	if len(related) != len(want) || related[0] != want[0] {
+		t.Errorf("related regions of the double close: %+v, want %+v", related, want)
+	}
+}
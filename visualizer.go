@@ -4,12 +4,15 @@ import (
 	"fmt"
 	"os"
 	"strings"
+
+	"channeling/chanflow"
 )
 
 type GraphNode struct {
 	ID       string
 	Label    string
 	Type     string
+	Group    string
 	Location string
 }
 
@@ -20,34 +23,95 @@ type GraphEdge struct {
 	Location string
 }
 
-func generateGraph(channels map[string]*ChannelInfo) string {
+// flowEdge is an operation on a channel as drawn in the graphs: an edge
+// between the channel and the goroutine or function performing it.
+type flowEdge struct {
+	Actor     string
+	Channel   string
+	Label     string
+	ToChannel bool
+	Op        chanflow.Operation
+}
+
+// ends returns the nodes the edge goes from and to.
+func (e flowEdge) ends() (from, to string) {
+	if e.ToChannel {
+		return e.Actor, e.Channel
+	}
+	return e.Channel, e.Actor
+}
+
+// actorGroup returns the group of the node of the actor of e.
+func (e flowEdge) actorGroup() string {
+	if e.Op.Goroutine != "" {
+		return "goroutine"
+	}
+	return "function"
+}
+
+// flowEdges returns the edges of channel, pointing into the channel for
+// sends and closes and out of it for receives and range loops.
+func flowEdges(channel *chanflow.ChannelInfo) []flowEdge {
+	var edges []flowEdge
+	for _, kind := range []struct {
+		label     string
+		ops       []chanflow.Operation
+		toChannel bool
+	}{
+		{"send", channel.SendOps, true},
+		{"receive", channel.ReceiveOps, false},
+		{"close", channel.CloseOps, true},
+		{"range", channel.RangeOps, false},
+	} {
+		for _, op := range kind.ops {
+			edges = append(edges, flowEdge{
+				Actor:     op.Actor(),
+				Channel:   channel.ID,
+				Label:     kind.label,
+				ToChannel: kind.toChannel,
+				Op:        op,
+			})
+		}
+	}
+	return edges
+}
+
+// dotLabel escapes s for a line of a quoted DOT label, in which quotes and
+// backslashes, as in struct tags, would end the string or start an escape.
+func dotLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
+
+func generateGraph(report *chanflow.Report) string {
 	var nodes []GraphNode
 	var edges []GraphEdge
+	actors := make(map[string]bool)
 
-	for name, channel := range channels {
+	for _, channel := range report.Channels {
+		name := channel.ID
+		nodeType := channel.Type
+		if len(channel.Capacities) > 0 {
+			nodeType += fmt.Sprintf(" (cap %s)", strings.Join(channel.Capacities, ", "))
+		}
 		nodes = append(nodes, GraphNode{
 			ID:       name,
-			Label:    name,
-			Type:     channel.Type,
+			Label:    channel.Name,
+			Type:     nodeType,
+			Group:    "channel",
 			Location: channel.Location,
 		})
 
-		for _, sendOp := range channel.SendOps {
-			edges = append(edges, GraphEdge{
-				From:     "main",
-				To:       name,
-				Label:    "send",
-				Location: sendOp,
-			})
-		}
-
-		for _, receiveOp := range channel.ReceiveOps {
-			edges = append(edges, GraphEdge{
-				From:     name,
-				To:       "main",
-				Label:    "receive",
-				Location: receiveOp,
-			})
+		for _, e := range flowEdges(channel) {
+			if !actors[e.Actor] {
+				actors[e.Actor] = true
+				nodes = append(nodes, GraphNode{
+					ID:    e.Actor,
+					Label: e.Actor,
+					Group: e.actorGroup(),
+				})
+			}
+			from, to := e.ends()
+			edges = append(edges, GraphEdge{From: from, To: to, Label: e.Label, Location: e.Op.Location})
 		}
 	}
 
@@ -58,13 +122,22 @@ func generateGraph(channels map[string]*ChannelInfo) string {
 	dot.WriteString("  edge [color=gray];\n\n")
 
 	for _, node := range nodes {
-		dot.WriteString(fmt.Sprintf("  %s [label=\"%s\\n%s\\n%s\"];\n",
-			node.ID, node.Label, node.Type, node.Location))
+		switch node.Group {
+		case "goroutine":
+			dot.WriteString(fmt.Sprintf("  %q [label=\"%s\", shape=ellipse, fillcolor=lightyellow];\n",
+				node.ID, dotLabel(node.Label)))
+		case "function":
+			dot.WriteString(fmt.Sprintf("  %q [label=\"%s\", shape=ellipse, fillcolor=lightgray];\n",
+				node.ID, dotLabel(node.Label)))
+		default:
+			dot.WriteString(fmt.Sprintf("  %q [label=\"%s\\n%s\\n%s\"];\n",
+				node.ID, dotLabel(node.Label), dotLabel(node.Type), dotLabel(node.Location)))
+		}
 	}
 
 	for _, edge := range edges {
-		dot.WriteString(fmt.Sprintf("  %s -> %s [label=\"%s\\n%s\"];\n",
-			edge.From, edge.To, edge.Label, edge.Location))
+		dot.WriteString(fmt.Sprintf("  %q -> %q [label=\"%s\\n%s\"];\n",
+			edge.From, edge.To, dotLabel(edge.Label), dotLabel(edge.Location)))
 	}
 
 	dot.WriteString("}\n")
@@ -75,14 +148,18 @@ func saveGraphToFile(dotContent string, filename string) error {
 	return os.WriteFile(filename, []byte(dotContent), 0644)
 }
 
-func visualizeChannels(channels map[string]*ChannelInfo) {
-	dotContent := generateGraph(channels)
-	err := saveGraphToFile(dotContent, "channel_flow.dot")
+func visualizeChannels(report *chanflow.Report, filename string) error {
+	dotContent := generateGraph(report)
+	if filename == "-" {
+		_, err := fmt.Print(dotContent)
+		return err
+	}
+	err := saveGraphToFile(dotContent, filename)
 	if err != nil {
-		fmt.Printf("Error saving graph: %v\n", err)
-		return
+		return fmt.Errorf("saving graph: %w", err)
 	}
-	fmt.Println("\nGraph visualization saved to channel_flow.dot")
+	fmt.Printf("Graph visualization saved to %s\n", filename)
 	fmt.Println("To view the graph, install Graphviz and run:")
-	fmt.Println("dot -Tpng channel_flow.dot -o channel_flow.png")
+	fmt.Printf("dot -Tpng %s -o %s.png\n", filename, strings.TrimSuffix(filename, ".dot"))
+	return nil
 } 
\ No newline at end of file
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"channeling/chanflow"
+)
+
+func TestGraphLabelEscaping(t *testing.T) {
+	report := &chanflow.Report{Channels: []*chanflow.ChannelInfo{{
+		ID:         "ch@p.go:4:2",
+		Name:       "ch",
+		Type:       `chan struct{A int "json:\"a\""}`,
+		Capacities: []string{"1"},
+		Location:   "p.go:4",
+	}}}
+	want := `  "ch@p.go:4:2" [label="ch\nchan struct{A int \"json:\\\"a\\\"\"} (cap 1)\np.go:4"];`
+	graph := generateGraph(report)
+	if !strings.Contains(graph, want+"\n") {
+		t.Errorf("graph:\n%s\nwant the line:\n%s", graph, want)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"io/fs"
+	"maps"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// watchInterval is how often serve --watch looks for changed files.
+const watchInterval = time.Second
+
+// fileStamp is what tells a changed file apart from an unchanged one.
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// watchTree calls changed whenever a Go source file or a module file under
+// root is added, removed or modified, polling every interval until ctx is
+// done. Calls are never concurrent: changes made while changed runs are
+// reported once it returns.
+func watchTree(ctx context.Context, root string, interval time.Duration, changed func()) {
+	last := snapshotTree(root)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		current := snapshotTree(root)
+		if maps.Equal(last, current) {
+			continue
+		}
+		last = current
+		changed()
+	}
+}
+
+// snapshotTree stamps the files under root that the analysis may depend on:
+// Go source files and go.mod, go.sum and go.work files, outside the
+// directories the go command ignores. Files that cannot be read are left
+// out.
+func snapshotTree(root string) map[string]fileStamp {
+	files := make(map[string]fileStamp)
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if path != root && (name == "vendor" || name == "testdata" ||
+				strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch {
+		case strings.HasSuffix(name, ".go"), name == "go.mod", name == "go.sum", name == "go.work":
+		default:
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			files[path] = fileStamp{size: info.Size(), modTime: info.ModTime()}
+		}
+		return nil
+	})
+	return files
+}
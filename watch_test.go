@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSnapshotTree(t *testing.T) {
+	root := t.TempDir()
+	source := filepath.Join(root, "pkg", "a.go")
+	writeFile(t, source, "package pkg\n")
+	writeFile(t, filepath.Join(root, "go.mod"), "module example.com/m\n")
+	writeFile(t, filepath.Join(root, "notes.txt"), "not Go\n")
+	writeFile(t, filepath.Join(root, "testdata", "b.go"), "package testdata\n")
+	writeFile(t, filepath.Join(root, ".hidden", "c.go"), "package hidden\n")
+
+	files := snapshotTree(root)
+	if len(files) != 2 {
+		t.Fatalf("snapshotTree stamped %v, want only go.mod and pkg/a.go", files)
+	}
+	before, ok := files[source]
+	if !ok {
+		t.Fatalf("snapshotTree did not stamp %s", source)
+	}
+
+	writeFile(t, source, "package pkg\n\nfunc F() {}\n")
+	if after := snapshotTree(root)[source]; after == before {
+		t.Errorf("the stamp of %s did not change with its content", source)
+	}
+
+	if err := os.Remove(source); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := snapshotTree(root)[source]; ok {
+		t.Errorf("snapshotTree stamped %s after its removal", source)
+	}
+}
+
+func TestWatchTree(t *testing.T) {
+	root := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	changes := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		watchTree(ctx, root, 10*time.Millisecond, func() { changes <- struct{}{} })
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// expect waits for watchTree to report the change made by step.
+	expect := func(step string) {
+		t.Helper()
+		select {
+		case <-changes:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("watchTree did not report that %s", step)
+		}
+	}
+
+	// Give watchTree the time to take its first snapshot of the empty tree.
+	time.Sleep(50 * time.Millisecond)
+	source := filepath.Join(root, "main.go")
+	writeFile(t, source, "package main\n")
+	expect("main.go was created")
+	writeFile(t, source, "package main\n\nfunc main() {}\n")
+	expect("main.go was edited")
+	if err := os.Remove(source); err != nil {
+		t.Fatal(err)
+	}
+	expect("main.go was removed")
+}
+
+// writeModule writes files to a new temporary directory and returns it.
+func writeModule(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		writeFile(t, filepath.Join(dir, name), content)
+	}
+	return dir
+}
+
+func writeFile(t *testing.T, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
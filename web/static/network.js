@@ -0,0 +1,613 @@
+// network.js draws the channel flow graph on a canvas, laid out by a force
+// simulation. It implements the part of the vis-network API the visualizer
+// uses, DataSet and Network, and draws the page when the vis-network build
+// is not vendored under vis-network/.
+(function (global) {
+    'use strict';
+
+    const NODE_HEIGHT = 30;
+    const LINE_HEIGHT = 18;
+    const NODE_MIN_WIDTH = 100;
+    const NODE_MAX_WIDTH = 200;
+    const NODE_FONT = '14px sans-serif';
+    const EDGE_FONT = '12px sans-serif';
+    const SPRING_LENGTH = 200;
+
+    let nextID = 0;
+
+    // DataSet holds items by their id, assigning one to items without, and
+    // tells its subscribers about every change.
+    class DataSet {
+        constructor(items) {
+            this.items = new Map();
+            this.listeners = [];
+            if (items) {
+                this.add(items);
+            }
+        }
+
+        get length() {
+            return this.items.size;
+        }
+
+        add(items) {
+            for (const item of [].concat(items)) {
+                if (item.id === undefined) {
+                    item.id = 'item-' + nextID++;
+                }
+                this.items.set(item.id, item);
+            }
+            this.changed();
+        }
+
+        // update merges items into those with the same id and adds the
+        // others.
+        update(items) {
+            for (const item of [].concat(items)) {
+                const existing = this.items.get(item.id);
+                if (existing) {
+                    Object.assign(existing, item);
+                } else {
+                    this.add(item);
+                }
+            }
+            this.changed();
+        }
+
+        remove(ids) {
+            for (const id of [].concat(ids)) {
+                this.items.delete(typeof id === 'object' ? id.id : id);
+            }
+            this.changed();
+        }
+
+        clear() {
+            this.items.clear();
+            this.changed();
+        }
+
+        get(id) {
+            if (id === undefined) {
+                return Array.from(this.items.values());
+            }
+            return this.items.get(id) || null;
+        }
+
+        getIds() {
+            return Array.from(this.items.keys());
+        }
+
+        forEach(callback) {
+            this.items.forEach((item, id) => callback(item, id));
+        }
+
+        on(event, callback) {
+            this.listeners.push(callback);
+        }
+
+        off(event, callback) {
+            this.listeners = this.listeners.filter(listener => listener !== callback);
+        }
+
+        changed() {
+            this.listeners.forEach(listener => listener());
+        }
+    }
+
+    // Network draws the nodes and edges of its data in container. Nodes are
+    // boxes with the background of node.color, the border and highlight
+    // colors of options.nodes.color, and edges arrows labeled
+    // with their label; node.hidden hides a node and its edges, and
+    // node.title is shown when hovering it. Nodes can be dragged, the view
+    // panned and zoomed.
+    class Network {
+        constructor(container, data, options) {
+            this.container = container;
+            this.options = { nodes: {}, physics: { enabled: true } };
+            this.setOptions(options || {});
+            this.handlers = {};
+            this.positions = new Map();
+            this.scale = 1;
+            this.offset = { x: 0, y: 0 };
+            this.hovered = null;
+            this.dragging = null;
+            this.running = false;
+            this.redraw = () => this.schedule();
+
+            if (getComputedStyle(container).position === 'static') {
+                container.style.position = 'relative';
+            }
+            this.canvas = document.createElement('canvas');
+            this.canvas.style.display = 'block';
+            this.canvas.style.width = '100%';
+            this.canvas.style.height = '100%';
+            container.appendChild(this.canvas);
+            this.context = this.canvas.getContext('2d');
+            this.tooltip = document.createElement('div');
+            Object.assign(this.tooltip.style, {
+                position: 'absolute',
+                display: 'none',
+                pointerEvents: 'none',
+                whiteSpace: 'pre-line',
+                maxWidth: '400px',
+                padding: '6px 8px',
+                background: 'white',
+                border: '1px solid #ccc',
+                borderRadius: '4px',
+                boxShadow: '0 2px 4px rgba(0,0,0,0.1)',
+                fontSize: '12px',
+                zIndex: 1,
+            });
+            container.appendChild(this.tooltip);
+
+            this.listen();
+            new ResizeObserver(() => this.resize()).observe(container);
+            this.resize();
+            this.setData(data);
+            this.offset = { x: this.width / 2, y: this.height / 2 };
+        }
+
+        on(event, callback) {
+            (this.handlers[event] = this.handlers[event] || []).push(callback);
+        }
+
+        emit(event, params) {
+            (this.handlers[event] || []).forEach(callback => callback(params));
+        }
+
+        setData(data) {
+            for (const set of [this.nodes, this.edges]) {
+                if (set) {
+                    set.off('*', this.redraw);
+                }
+            }
+            this.nodes = data.nodes;
+            this.edges = data.edges;
+            this.nodes.on('*', this.redraw);
+            this.edges.on('*', this.redraw);
+            this.schedule();
+        }
+
+        setOptions(options) {
+            Object.assign(this.options.nodes, options.nodes || {});
+            Object.assign(this.options.physics, options.physics || {});
+            if (this.positions) {
+                this.schedule();
+            }
+        }
+
+        // stabilize runs iterations steps of the simulation at once, then
+        // fits the graph into view.
+        stabilize(iterations) {
+            const total = iterations || 1000;
+            this.place();
+            for (let i = 1; i <= total; i++) {
+                const moving = this.step();
+                if (i % 50 === 0) {
+                    this.emit('stabilizationProgress', { iterations: i, total });
+                }
+                if (!moving) {
+                    break;
+                }
+            }
+            this.emit('stabilizationIterationsDone');
+            this.fit();
+        }
+
+        // fit zooms and pans so that every visible node is in view.
+        fit(options) {
+            const nodes = this.visibleNodes().filter(node => this.positions.has(node.id));
+            if (nodes.length === 0) {
+                return;
+            }
+            let minX = Infinity, minY = Infinity, maxX = -Infinity, maxY = -Infinity;
+            for (const node of nodes) {
+                const box = this.nodeBox(node);
+                minX = Math.min(minX, box.x - box.w);
+                maxX = Math.max(maxX, box.x + box.w);
+                minY = Math.min(minY, box.y - box.h);
+                maxY = Math.max(maxY, box.y + box.h);
+            }
+            const scale = Math.min(1.5, this.width / (maxX - minX + 100), this.height / (maxY - minY + 100));
+            const target = {
+                scale,
+                x: this.width / 2 - scale * (minX + maxX) / 2,
+                y: this.height / 2 - scale * (minY + maxY) / 2,
+            };
+            const duration = options && options.animation ? options.animation.duration || 1000 : 0;
+            if (!duration) {
+                this.scale = target.scale;
+                this.offset = { x: target.x, y: target.y };
+                this.schedule();
+                return;
+            }
+            const start = { scale: this.scale, x: this.offset.x, y: this.offset.y };
+            const began = performance.now();
+            const animate = now => {
+                const t = clamp((now - began) / duration, 0, 1);
+                const e = t < 0.5 ? 2 * t * t : 1 - Math.pow(-2 * t + 2, 2) / 2;
+                this.scale = start.scale + (target.scale - start.scale) * e;
+                this.offset = {
+                    x: start.x + (target.x - start.x) * e,
+                    y: start.y + (target.y - start.y) * e,
+                };
+                this.draw();
+                if (t < 1) {
+                    requestAnimationFrame(animate);
+                }
+            };
+            requestAnimationFrame(animate);
+        }
+
+        visibleNodes() {
+            return this.nodes.get().filter(node => !node.hidden);
+        }
+
+        visibleEdges() {
+            return this.edges.get().filter(edge => {
+                const from = this.nodes.get(edge.from);
+                const to = this.nodes.get(edge.to);
+                return from && to && !from.hidden && !to.hidden;
+            });
+        }
+
+        // place gives new nodes a position next to a placed neighbor, or
+        // around the center, and forgets removed nodes.
+        place() {
+            for (const id of this.positions.keys()) {
+                if (!this.nodes.get(id)) {
+                    this.positions.delete(id);
+                }
+            }
+            const spread = 50 * Math.sqrt(this.nodes.length + 1);
+            for (const node of this.nodes.get()) {
+                if (this.positions.has(node.id)) {
+                    continue;
+                }
+                let x = (Math.random() - 0.5) * spread * 2;
+                let y = (Math.random() - 0.5) * spread * 2;
+                for (const edge of this.edges.get()) {
+                    const other = edge.from === node.id ? edge.to : edge.to === node.id ? edge.from : null;
+                    const p = other !== null && this.positions.get(other);
+                    if (p) {
+                        const angle = Math.random() * 2 * Math.PI;
+                        x = p.x + Math.cos(angle) * SPRING_LENGTH / 2;
+                        y = p.y + Math.sin(angle) * SPRING_LENGTH / 2;
+                        break;
+                    }
+                }
+                this.positions.set(node.id, { x, y, vx: 0, vy: 0 });
+            }
+        }
+
+        // step advances the simulation: nodes repel each other, edges pull
+        // their ends to SPRING_LENGTH apart and a weak gravity keeps the
+        // graph centered. It reports whether any node still moves.
+        step() {
+            const nodes = this.visibleNodes().map(node => this.positions.get(node.id));
+            const forces = new Map(nodes.map(p => [p, { x: 0, y: 0 }]));
+            for (let i = 0; i < nodes.length; i++) {
+                const a = nodes[i];
+                const fa = forces.get(a);
+                fa.x -= 0.002 * a.x;
+                fa.y -= 0.002 * a.y;
+                for (let j = i + 1; j < nodes.length; j++) {
+                    const b = nodes[j];
+                    let dx = a.x - b.x, dy = a.y - b.y;
+                    if (dx === 0 && dy === 0) {
+                        dx = Math.random() - 0.5;
+                        dy = Math.random() - 0.5;
+                    }
+                    const d2 = Math.max(dx * dx + dy * dy, 100);
+                    const f = 30000 / d2 / Math.sqrt(d2);
+                    fa.x += dx * f;
+                    fa.y += dy * f;
+                    forces.get(b).x -= dx * f;
+                    forces.get(b).y -= dy * f;
+                }
+            }
+            for (const edge of this.visibleEdges()) {
+                const a = this.positions.get(edge.from);
+                const b = this.positions.get(edge.to);
+                const dx = b.x - a.x, dy = b.y - a.y;
+                const d = Math.max(Math.sqrt(dx * dx + dy * dy), 1);
+                const f = 0.02 * (d - SPRING_LENGTH) / d;
+                forces.get(a).x += dx * f;
+                forces.get(a).y += dy * f;
+                forces.get(b).x -= dx * f;
+                forces.get(b).y -= dy * f;
+            }
+            let moving = false;
+            for (const p of nodes) {
+                if (p === this.dragging) {
+                    continue;
+                }
+                const f = forces.get(p);
+                p.vx = clamp((p.vx + f.x) * 0.85, -30, 30);
+                p.vy = clamp((p.vy + f.y) * 0.85, -30, 30);
+                p.x += p.vx;
+                p.y += p.vy;
+                moving = moving || Math.abs(p.vx) > 0.1 || Math.abs(p.vy) > 0.1;
+            }
+            return moving;
+        }
+
+        // schedule redraws the graph on the next frame, animating the
+        // simulation until it settles while physics is enabled.
+        schedule() {
+            if (this.running) {
+                return;
+            }
+            this.running = true;
+            requestAnimationFrame(() => this.frame());
+        }
+
+        frame() {
+            this.place();
+            let moving = false;
+            if (this.options.physics.enabled) {
+                this.step();
+                moving = this.step();
+            }
+            this.draw();
+            if (moving || this.dragging) {
+                requestAnimationFrame(() => this.frame());
+            } else {
+                this.running = false;
+            }
+        }
+
+        resize() {
+            const ratio = window.devicePixelRatio || 1;
+            this.width = this.container.clientWidth;
+            this.height = this.container.clientHeight;
+            this.canvas.width = this.width * ratio;
+            this.canvas.height = this.height * ratio;
+            this.draw();
+        }
+
+        // nodeBox returns the center and half the width and height of the
+        // box of a placed node, which fits every line of its label.
+        nodeBox(node) {
+            const p = this.positions.get(node.id);
+            const lines = String(node.label || '').split('\n');
+            this.context.font = NODE_FONT;
+            const width = Math.max(...lines.map(line => this.context.measureText(line).width)) + 20;
+            return {
+                x: p.x,
+                y: p.y,
+                w: clamp(width, NODE_MIN_WIDTH, NODE_MAX_WIDTH) / 2,
+                h: Math.max(NODE_HEIGHT, lines.length * LINE_HEIGHT + 12) / 2,
+                lines,
+            };
+        }
+
+        draw() {
+            if (!this.nodes) {
+                return;
+            }
+            const ctx = this.context;
+            const ratio = window.devicePixelRatio || 1;
+            ctx.setTransform(1, 0, 0, 1, 0, 0);
+            ctx.clearRect(0, 0, this.canvas.width, this.canvas.height);
+            ctx.setTransform(ratio * this.scale, 0, 0, ratio * this.scale, ratio * this.offset.x, ratio * this.offset.y);
+
+            const boxes = new Map();
+            for (const node of this.visibleNodes()) {
+                if (this.positions.has(node.id)) {
+                    boxes.set(node.id, this.nodeBox(node));
+                }
+            }
+            this.drawEdges(ctx, boxes);
+            for (const node of this.visibleNodes()) {
+                const box = boxes.get(node.id);
+                if (box) {
+                    this.drawNode(ctx, node, box);
+                }
+            }
+        }
+
+        drawEdges(ctx, boxes) {
+            // Edges between the same nodes are bent apart.
+            const pairs = new Map();
+            const edges = this.visibleEdges().filter(edge => boxes.has(edge.from) && boxes.has(edge.to));
+            for (const edge of edges) {
+                const key = [edge.from, edge.to].sort().join('\u0000');
+                (pairs.get(key) || pairs.set(key, []).get(key)).push(edge);
+            }
+            for (const [key, group] of pairs) {
+                const [first] = key.split('\u0000');
+                group.forEach((edge, i) => {
+                    const bend = (i - (group.length - 1) / 2) * 40;
+                    this.drawEdge(ctx, edge, boxes.get(edge.from), boxes.get(edge.to), edge.from === first ? bend : -bend);
+                });
+            }
+        }
+
+        drawEdge(ctx, edge, from, to, bend) {
+            const dx = to.x - from.x, dy = to.y - from.y;
+            const d = Math.max(Math.sqrt(dx * dx + dy * dy), 1);
+            const control = { x: (from.x + to.x) / 2 - dy / d * bend, y: (from.y + to.y) / 2 + dx / d * bend };
+            const at = t => ({
+                x: (1 - t) * (1 - t) * from.x + 2 * (1 - t) * t * control.x + t * t * to.x,
+                y: (1 - t) * (1 - t) * from.y + 2 * (1 - t) * t * control.y + t * t * to.y,
+            });
+            let start = 0, end = 1;
+            while (start < 1 && inside(at(start), from)) {
+                start += 0.01;
+            }
+            while (end > start && inside(at(end), to)) {
+                end -= 0.01;
+            }
+            if (end <= start) {
+                return;
+            }
+            const highlighted = this.hovered !== null && (edge.from === this.hovered || edge.to === this.hovered);
+            const color = highlighted ? '#FF0000' : '#848484';
+            ctx.strokeStyle = color;
+            ctx.fillStyle = color;
+            ctx.lineWidth = 1;
+            ctx.beginPath();
+            for (let t = start; t < end; t += 0.02) {
+                const p = at(t);
+                t === start ? ctx.moveTo(p.x, p.y) : ctx.lineTo(p.x, p.y);
+            }
+            const tip = at(end);
+            const back = at(Math.max(start, end - 0.02));
+            ctx.lineTo(tip.x, tip.y);
+            ctx.stroke();
+
+            const angle = Math.atan2(tip.y - back.y, tip.x - back.x);
+            ctx.beginPath();
+            ctx.moveTo(tip.x, tip.y);
+            ctx.lineTo(tip.x - 10 * Math.cos(angle - 0.4), tip.y - 10 * Math.sin(angle - 0.4));
+            ctx.lineTo(tip.x - 10 * Math.cos(angle + 0.4), tip.y - 10 * Math.sin(angle + 0.4));
+            ctx.closePath();
+            ctx.fill();
+
+            if (edge.label) {
+                const mid = at((start + end) / 2);
+                ctx.font = EDGE_FONT;
+                ctx.textAlign = 'center';
+                ctx.textBaseline = 'middle';
+                const width = ctx.measureText(edge.label).width + 4;
+                ctx.fillStyle = 'white';
+                ctx.fillRect(mid.x - width / 2, mid.y - 8, width, 16);
+                ctx.fillStyle = color;
+                ctx.fillText(edge.label, mid.x, mid.y);
+            }
+        }
+
+        drawNode(ctx, node, box) {
+            const colors = this.options.nodes.color || {};
+            const highlight = colors.highlight || {};
+            const highlighted = node.id === this.hovered;
+            ctx.beginPath();
+            roundRect(ctx, box.x - box.w, box.y - box.h, box.w * 2, box.h * 2, 4);
+            ctx.fillStyle = highlighted ? highlight.background || '#FFB1B1' : (node.color && node.color.background) || '#D2E5FF';
+            ctx.fill();
+            ctx.lineWidth = highlighted ? 2 : 1;
+            ctx.strokeStyle = highlighted ? highlight.border || '#FF0000' : colors.border || '#2B7CE9';
+            ctx.stroke();
+
+            ctx.font = NODE_FONT;
+            ctx.textAlign = 'center';
+            ctx.textBaseline = 'middle';
+            ctx.fillStyle = '#343434';
+            box.lines.forEach((line, i) => {
+                const y = box.y + (i - (box.lines.length - 1) / 2) * LINE_HEIGHT;
+                ctx.fillText(truncate(ctx, line, box.w * 2 - 20), box.x, y);
+            });
+        }
+
+        // nodeAt returns the id of the visible node drawn at the screen
+        // point x, y, if any.
+        nodeAt(x, y) {
+            const point = { x: (x - this.offset.x) / this.scale, y: (y - this.offset.y) / this.scale };
+            const nodes = this.visibleNodes();
+            for (let i = nodes.length - 1; i >= 0; i--) {
+                if (this.positions.has(nodes[i].id) && inside(point, this.nodeBox(nodes[i]))) {
+                    return nodes[i].id;
+                }
+            }
+            return null;
+        }
+
+        listen() {
+            const canvas = this.canvas;
+            let last = null;
+            const point = event => {
+                const rect = canvas.getBoundingClientRect();
+                return { x: event.clientX - rect.left, y: event.clientY - rect.top };
+            };
+            canvas.addEventListener('mousedown', event => {
+                last = point(event);
+                const id = this.nodeAt(last.x, last.y);
+                this.dragging = id === null ? null : this.positions.get(id);
+                this.tooltip.style.display = 'none';
+            });
+            canvas.addEventListener('mousemove', event => {
+                const p = point(event);
+                if (last) {
+                    const dx = p.x - last.x, dy = p.y - last.y;
+                    last = p;
+                    if (this.dragging) {
+                        this.dragging.x += dx / this.scale;
+                        this.dragging.y += dy / this.scale;
+                        this.dragging.vx = this.dragging.vy = 0;
+                    } else {
+                        this.offset.x += dx;
+                        this.offset.y += dy;
+                    }
+                    this.schedule();
+                    return;
+                }
+                const id = this.nodeAt(p.x, p.y);
+                if (id !== this.hovered) {
+                    this.hovered = id;
+                    this.schedule();
+                }
+                const node = id === null ? null : this.nodes.get(id);
+                if (node && node.title) {
+                    this.tooltip.textContent = node.title;
+                    this.tooltip.style.left = (p.x + 12) + 'px';
+                    this.tooltip.style.top = (p.y + 12) + 'px';
+                    this.tooltip.style.display = 'block';
+                } else {
+                    this.tooltip.style.display = 'none';
+                }
+                canvas.style.cursor = id === null ? 'default' : 'pointer';
+            });
+            const release = () => {
+                last = null;
+                this.dragging = null;
+                this.schedule();
+            };
+            canvas.addEventListener('mouseup', release);
+            canvas.addEventListener('mouseleave', () => {
+                release();
+                this.hovered = null;
+                this.tooltip.style.display = 'none';
+            });
+            canvas.addEventListener('wheel', event => {
+                event.preventDefault();
+                const p = point(event);
+                const scale = clamp(this.scale * Math.exp(-event.deltaY * 0.001), 0.05, 5);
+                this.offset.x = p.x - (p.x - this.offset.x) * scale / this.scale;
+                this.offset.y = p.y - (p.y - this.offset.y) * scale / this.scale;
+                this.scale = scale;
+                this.schedule();
+            }, { passive: false });
+        }
+    }
+
+    function clamp(value, min, max) {
+        return Math.min(max, Math.max(min, value));
+    }
+
+    function inside(point, box) {
+        return Math.abs(point.x - box.x) <= box.w && Math.abs(point.y - box.y) <= box.h;
+    }
+
+    function roundRect(ctx, x, y, w, h, r) {
+        ctx.moveTo(x + r, y);
+        ctx.arcTo(x + w, y, x + w, y + h, r);
+        ctx.arcTo(x + w, y + h, x, y + h, r);
+        ctx.arcTo(x, y + h, x, y, r);
+        ctx.arcTo(x, y, x + w, y, r);
+        ctx.closePath();
+    }
+
+    // truncate shortens text with an ellipsis to fit width.
+    function truncate(ctx, text, width) {
+        if (ctx.measureText(text).width <= width) {
+            return text;
+        }
+        while (text.length > 1 && ctx.measureText(text + '…').width > width) {
+            text = text.slice(0, -1);
+        }
+        return text + '…';
+    }
+
+    global.vis = { DataSet, Network };
+})(window);
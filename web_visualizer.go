@@ -1,19 +1,62 @@
 package main
 
 import (
+	"context"
+	"embed"
 	"encoding/json"
 	"fmt"
 	"html/template"
+	"io/fs"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"channeling/chanflow"
 )
 
+// webFiles holds the page of the visualizer and, under web/static, the
+// style sheet and the scripts drawing the graph, so that it works without
+// network access.
+//
+//go:embed web
+var webFiles embed.FS
+
+var pageTemplate = template.Must(template.ParseFS(webFiles, "web/index.html"))
+
+// visNetwork is the vis-network build make web-assets vendors in webFiles.
+// Without it, the page draws the graph with web/static/network.js, which
+// implements the part of the vis-network API it uses.
+const visNetwork = "web/static/vis-network/vis-network.min.js"
+
 type WebNode struct {
-	ID       string `json:"id"`
-	Label    string `json:"label"`
-	Type     string `json:"type"`
-	Group    string `json:"group"`
-	Status   string `json:"status"`
-	Tooltip  string `json:"title"`
+	ID      string    `json:"id"`
+	Label   string    `json:"label"`
+	Type    string    `json:"type"`
+	Group   string    `json:"group"`
+	Status  string    `json:"status"`
+	Owner   string    `json:"owner,omitempty"`
+	Tooltip string    `json:"title"`
+	Color   nodeColor `json:"color"`
+}
+
+// nodeColor is the color of a node; vis-network takes the border and
+// highlight colors from the options of the page.
+type nodeColor struct {
+	Background string `json:"background"`
+}
+
+// statusColors are the background colors of nodes by status, matching the
+// legend of the page.
+var statusColors = map[string]string{
+	"normal":       "#D2E5FF",
+	"deadlock":     "#FF6B6B",
+	"close-panic":  "#C77DFF",
+	"leak":         "#FFA94D",
+	"dangling":     "#FFB1B1",
+	"receive-only": "#FFD700",
+	"send-only":    "#98FB98",
 }
 
 type WebEdge struct {
@@ -27,493 +70,256 @@ type WebGraph struct {
 	Edges []WebEdge `json:"edges"`
 }
 
-func generateWebGraph(channels map[string]*ChannelInfo) WebGraph {
-	var graph WebGraph
+// closeDiagnostics returns the diagnostics of channel about how it is
+// closed.
+func closeDiagnostics(channel *chanflow.ChannelInfo) []chanflow.Diagnostic {
+	var diags []chanflow.Diagnostic
+	for _, rule := range []string{chanflow.RuleDoubleClose, chanflow.RuleSendAfterClose, chanflow.RuleNonOwnerClose, chanflow.RuleMultipleClosers} {
+		diags = append(diags, channel.DiagnosticsFor(rule)...)
+	}
+	return diags
+}
 
-	graph.Nodes = append(graph.Nodes, WebNode{
-		ID:      "main",
-		Label:   "Main",
-		Type:    "program",
-		Group:   "main",
-		Status:  "normal",
-		Tooltip: "Main program",
-	})
+func generateWebGraph(report *chanflow.Report) WebGraph {
+	var graph WebGraph
+	actors := make(map[string]bool)
 
-	for name, channel := range channels {
+	for _, channel := range report.Channels {
+		name := channel.ID
 		status := "normal"
-		tooltip := fmt.Sprintf("Type: %s\nDeclaration: %s", channel.Type, channel.Declaration)
-		
-		sendCount := 0
-		receiveCount := 0
-		
-		for _, op := range channel.SendOps {
-			if op != "" {
-				sendCount++
-			}
+		tooltip := fmt.Sprintf("Kind: %s\nType: %s\nElement Type: %s\nDirection: %s\nDeclaration: %s",
+			channel.Kind, channel.Type, channel.ElemType, channel.Direction, channel.Declaration)
+		if len(channel.Capacities) > 0 {
+			tooltip += fmt.Sprintf("\nCapacity: %s", strings.Join(channel.Capacities, ", "))
 		}
-		
-		for _, op := range channel.ReceiveOps {
-			if op != "" {
-				receiveCount++
-			}
+		if len(channel.Aliases) > 0 {
+			tooltip += fmt.Sprintf("\nAliases: %s", strings.Join(channel.Aliases, ", "))
+		}
+		if channel.Owner != "" {
+			tooltip += fmt.Sprintf("\nOwner: %s", channel.Owner)
 		}
 		
-		if sendCount == 0 && receiveCount == 0 {
+		if deadlocks := channel.DiagnosticsFor(chanflow.RuleDeadlock); len(deadlocks) > 0 {
+			status = "deadlock"
+			tooltip += "\n⛔ Deadlock: " + deadlocks[0].Message
+		} else if closes := closeDiagnostics(channel); len(closes) > 0 {
+			status = "close-panic"
+			tooltip += "\n💥 Panicking close: " + closes[0].Message
+		} else if leaks := channel.DiagnosticsFor(chanflow.RuleLeak); len(leaks) > 0 {
+			status = "leak"
+			tooltip += "\n⚠️ Goroutine leak: " + leaks[0].Message
+		} else if len(channel.DiagnosticsFor(chanflow.RuleDangling)) > 0 {
 			status = "dangling"
-			tooltip += "\n⚠️ Dangling channel: No send or receive operations"
-		} else if sendCount == 0 {
+			tooltip += "\n⚠️ Dangling channel: No send, receive, close or range operations"
+		} else if len(channel.DiagnosticsFor(chanflow.RuleReceiveOnly)) > 0 {
 			status = "receive-only"
-			tooltip += "\n⚠️ Receive-only channel: No send operations"
-		} else if receiveCount == 0 {
+			tooltip += "\n⚠️ Receive-only channel: No send or close operations"
+		} else if len(channel.DiagnosticsFor(chanflow.RuleSendOnly)) > 0 {
 			status = "send-only"
 			tooltip += "\n⚠️ Send-only channel: No receive operations"
 		}
 
 		graph.Nodes = append(graph.Nodes, WebNode{
 			ID:      name,
-			Label:   name,
+			Label:   channel.Name,
 			Type:    channel.Type,
 			Group:   "channel",
 			Status:  status,
+			Owner:   channel.Owner,
 			Tooltip: tooltip,
+			Color:   nodeColor{Background: statusColors[status]},
 		})
 
-		for range channel.SendOps {
-			graph.Edges = append(graph.Edges, WebEdge{
-				From:  "main",
-				To:    name,
-				Label: "send",
-			})
-		}
-
-		for range channel.ReceiveOps {
-			graph.Edges = append(graph.Edges, WebEdge{
-				From:  name,
-				To:    "main",
-				Label: "receive",
-			})
+		for _, e := range flowEdges(channel) {
+			if !actors[e.Actor] {
+				actors[e.Actor] = true
+				group := e.actorGroup()
+				graph.Nodes = append(graph.Nodes, WebNode{
+					ID:      e.Actor,
+					Label:   e.Actor,
+					Type:    group,
+					Group:   group,
+					Status:  "normal",
+					Tooltip: e.Actor,
+					Color:   nodeColor{Background: statusColors["normal"]},
+				})
+			}
+			from, to := e.ends()
+			graph.Edges = append(graph.Edges, WebEdge{From: from, To: to, Label: e.Label})
 		}
 	}
 
 	return graph
 }
 
-func startWebServer(channels map[string]*ChannelInfo) {
-	graph := generateWebGraph(channels)
-
-	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
-
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		tmpl := `
-<!DOCTYPE html>
-<html>
-<head>
-    <title>Channel Flow Visualization</title>
-    <script type="text/javascript" src="https://unpkg.com/vis-network/standalone/umd/vis-network.min.js"></script>
-    <link href="https://fonts.googleapis.com/css2?family=Inter:wght@400;500;600&display=swap" rel="stylesheet">
-    <style>
-        * {
-            margin: 0;
-            padding: 0;
-            box-sizing: border-box;
-            font-family: 'Inter', sans-serif;
-        }
-
-        body {
-            background-color: #f8f9fa;
-            color: #2c3e50;
-            line-height: 1.6;
-        }
-
-        .container {
-            max-width: 1400px;
-            margin: 0 auto;
-            padding: 20px;
-        }
-
-        .header {
-            background: white;
-            padding: 20px;
-            border-radius: 12px;
-            box-shadow: 0 2px 4px rgba(0,0,0,0.05);
-            margin-bottom: 20px;
-        }
-
-        .header h1 {
-            font-size: 24px;
-            font-weight: 600;
-            color: #1a1a1a;
-            margin-bottom: 8px;
-        }
-
-        .header p {
-            color: #666;
-            font-size: 14px;
-        }
-
-        .controls {
-            display: grid;
-            grid-template-columns: 1fr 300px;
-            gap: 20px;
-            margin-bottom: 20px;
-        }
-
-        .main-controls {
-            background: white;
-            padding: 20px;
-            border-radius: 12px;
-            box-shadow: 0 2px 4px rgba(0,0,0,0.05);
-        }
-
-        .button-group {
-            display: flex;
-            gap: 10px;
-            margin-bottom: 20px;
-        }
-
-        .button {
-            padding: 8px 16px;
-            background: #4a90e2;
-            color: white;
-            border: none;
-            border-radius: 6px;
-            cursor: pointer;
-            font-size: 14px;
-            font-weight: 500;
-            transition: all 0.2s ease;
-        }
-
-        .button:hover {
-            background: #357abd;
-            transform: translateY(-1px);
-        }
-
-        .button:active {
-            transform: translateY(0);
-        }
-
-        .sidebar {
-            background: white;
-            padding: 20px;
-            border-radius: 12px;
-            box-shadow: 0 2px 4px rgba(0,0,0,0.05);
-        }
-
-        .filter-controls {
-            margin-bottom: 20px;
-        }
-
-        .filter-controls h3 {
-            font-size: 16px;
-            font-weight: 600;
-            margin-bottom: 12px;
-            color: #1a1a1a;
-        }
-
-        .filter-group {
-            display: flex;
-            flex-direction: column;
-            gap: 8px;
-        }
-
-        .filter-item {
-            display: flex;
-            align-items: center;
-            gap: 8px;
-        }
-
-        .filter-checkbox {
-            width: 16px;
-            height: 16px;
-            accent-color: #4a90e2;
-        }
-
-        .filter-label {
-            font-size: 14px;
-            color: #4a4a4a;
-        }
-
-        .legend {
-            margin-top: 20px;
-        }
-
-        .legend h3 {
-            font-size: 16px;
-            font-weight: 600;
-            margin-bottom: 12px;
-            color: #1a1a1a;
-        }
-
-        .legend-item {
-            display: flex;
-            align-items: center;
-            gap: 10px;
-            margin-bottom: 8px;
-        }
-
-        .legend-color {
-            width: 16px;
-            height: 16px;
-            border-radius: 4px;
-            border: 1px solid rgba(0,0,0,0.1);
-        }
-
-        .legend-label {
-            font-size: 14px;
-            color: #4a4a4a;
-        }
-
-        #network {
-            background: white;
-            border-radius: 12px;
-            box-shadow: 0 2px 4px rgba(0,0,0,0.05);
-            height: 800px;
-        }
-
-        @media (max-width: 1200px) {
-            .controls {
-                grid-template-columns: 1fr;
-            }
-        }
-    </style>
-</head>
-<body>
-    <div class="container">
-        <div class="header">
-            <h1>Channel Flow Visualization</h1>
-            <p>Interactive visualization of Go channel usage patterns</p>
-        </div>
-
-        <div class="controls">
-            <div class="main-controls">
-                <div class="button-group">
-                    <button class="button" onclick="stabilize()">Stabilize Layout</button>
-                    <button class="button" onclick="togglePhysics()">Toggle Physics</button>
-                    <button class="button" onclick="resetView()">Reset View</button>
-                </div>
-                <div id="network"></div>
-            </div>
-
-            <div class="sidebar">
-                <div class="filter-controls">
-                    <h3>Filter Channels</h3>
-                    <div class="filter-group">
-                        <div class="filter-item">
-                            <input type="checkbox" id="showNormal" class="filter-checkbox" checked onchange="updateFilters()">
-                            <label for="showNormal" class="filter-label">Normal Channels</label>
-                        </div>
-                        <div class="filter-item">
-                            <input type="checkbox" id="showDangling" class="filter-checkbox" checked onchange="updateFilters()">
-                            <label for="showDangling" class="filter-label">Dangling Channels</label>
-                        </div>
-                        <div class="filter-item">
-                            <input type="checkbox" id="showReceiveOnly" class="filter-checkbox" checked onchange="updateFilters()">
-                            <label for="showReceiveOnly" class="filter-label">Receive-only Channels</label>
-                        </div>
-                        <div class="filter-item">
-                            <input type="checkbox" id="showSendOnly" class="filter-checkbox" checked onchange="updateFilters()">
-                            <label for="showSendOnly" class="filter-label">Send-only Channels</label>
-                        </div>
-                    </div>
-                </div>
-
-                <div class="legend">
-                    <h3>Channel Status</h3>
-                    <div class="legend-item">
-                        <div class="legend-color" style="background: #D2E5FF;"></div>
-                        <span class="legend-label">Normal Channel</span>
-                    </div>
-                    <div class="legend-item">
-                        <div class="legend-color" style="background: #FFB1B1;"></div>
-                        <span class="legend-label">Dangling Channel</span>
-                    </div>
-                    <div class="legend-item">
-                        <div class="legend-color" style="background: #FFD700;"></div>
-                        <span class="legend-label">Receive-only Channel</span>
-                    </div>
-                    <div class="legend-item">
-                        <div class="legend-color" style="background: #98FB98;"></div>
-                        <span class="legend-label">Send-only Channel</span>
-                    </div>
-                </div>
-            </div>
-        </div>
-    </div>
-
-    <script>
-        const nodes = new vis.DataSet({{.Nodes}});
-        const edges = new vis.DataSet({{.Edges}});
-        
-        const container = document.getElementById('network');
-        const data = { nodes, edges };
-        const options = {
-            nodes: {
-                shape: 'box',
-                margin: 10,
-                font: { size: 14 },
-                color: {
-                    background: function(node) {
-                        switch(node.status) {
-                            case 'dangling': return '#FFB1B1';
-                            case 'receive-only': return '#FFD700';
-                            case 'send-only': return '#98FB98';
-                            default: return '#D2E5FF';
-                        }
-                    },
-                    border: '#2B7CE9',
-                    highlight: { background: '#FFB1B1', border: '#FF0000' }
-                },
-                widthConstraint: {
-                    minimum: 100,
-                    maximum: 200
-                },
-                heightConstraint: {
-                    minimum: 30,
-                    maximum: 50
-                }
-            },
-            edges: {
-                arrows: { to: { enabled: true, scaleFactor: 1 } },
-                font: { size: 12, align: 'middle' },
-                color: { color: '#848484', highlight: '#FF0000' },
-                smooth: {
-                    type: 'continuous',
-                    forceDirection: 'none',
-                    roundness: 0.5
-                }
-            },
-            physics: {
-                enabled: true,
-                stabilization: {
-                    enabled: true,
-                    iterations: 1000,
-                    updateInterval: 50,
-                    fit: true
-                },
-                barnesHut: {
-                    gravitationalConstant: -1000,
-                    centralGravity: 0.05,
-                    springLength: 200,
-                    springConstant: 0.01,
-                    damping: 0.15,
-                    avoidOverlap: 0.5
-                },
-                maxVelocity: 30,
-                minVelocity: 0.2,
-                solver: 'barnesHut',
-                timestep: 0.3
-            },
-            layout: {
-                improvedLayout: true,
-                hierarchical: {
-                    enabled: false,
-                    direction: 'UD',
-                    sortMethod: 'directed'
-                }
-            },
-            interaction: {
-                dragNodes: true,
-                dragView: true,
-                zoomView: true,
-                hover: true,
-                tooltipDelay: 200,
-                hideEdgesOnDrag: true,
-                hideEdgesOnZoom: true
-            }
-        };
-        
-        const network = new vis.Network(container, data, options);
-
-        // Add event listeners
-        network.on("stabilizationProgress", function(params) {
-            console.log('Stabilization progress:', params.iterations, '/', params.total);
-        });
-
-        network.on("stabilizationIterationsDone", function() {
-            console.log('Stabilization finished');
-        });
-
-        // Control functions
-        function stabilize() {
-            network.stabilize(100);
-        }
-
-        function togglePhysics() {
-            options.physics.enabled = !options.physics.enabled;
-            network.setOptions(options);
-        }
-
-        function resetView() {
-            network.fit({
-                animation: {
-                    duration: 1000,
-                    easingFunction: 'easeInOutQuad'
-                }
-            });
-        }
+// graphVersion is a graph published by a graphFeed with its version,
+// which counts the graphs published before it.
+type graphVersion struct {
+	Version int
+	Graph   WebGraph
+}
 
-        function updateFilters() {
-            const showNormal = document.getElementById('showNormal').checked;
-            const showDangling = document.getElementById('showDangling').checked;
-            const showReceiveOnly = document.getElementById('showReceiveOnly').checked;
-            const showSendOnly = document.getElementById('showSendOnly').checked;
+// graphFeed holds the graph served to browsers and passes every new one on
+// to the browsers following /events.
+type graphFeed struct {
+	mu          sync.Mutex
+	current     graphVersion
+	subscribers map[chan graphVersion]struct{}
+}
 
-            nodes.forEach(node => {
-                if (node.id === 'main') {
-                    node.hidden = false;
-                    return;
-                }
+func newGraphFeed(graph WebGraph) *graphFeed {
+	return &graphFeed{
+		current:     graphVersion{Graph: graph},
+		subscribers: make(map[chan graphVersion]struct{}),
+	}
+}
 
-                switch(node.status) {
-                    case 'normal':
-                        node.hidden = !showNormal;
-                        break;
-                    case 'dangling':
-                        node.hidden = !showDangling;
-                        break;
-                    case 'receive-only':
-                        node.hidden = !showReceiveOnly;
-                        break;
-                    case 'send-only':
-                        node.hidden = !showSendOnly;
-                        break;
-                }
-            });
+// latest returns the last graph published.
+func (f *graphFeed) latest() graphVersion {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.current
+}
 
-            network.setData({ nodes, edges });
-        }
+// publish replaces the graph. Subscribers still holding an earlier graph
+// they have not received only get the new one.
+func (f *graphFeed) publish(graph WebGraph) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.current = graphVersion{Version: f.current.Version + 1, Graph: graph}
+	for ch := range f.subscribers {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- f.current
+	}
+}
 
-        // Initial stabilization
-        network.stabilize(100);
-    </script>
-</body>
-</html>`
+// subscribe returns a channel receiving the graphs published from now on,
+// starting with the latest one unless its version is seen, and a function
+// to unsubscribe.
+func (f *graphFeed) subscribe(seen string) (<-chan graphVersion, func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ch := make(chan graphVersion, 1)
+	if seen != strconv.Itoa(f.current.Version) {
+		ch <- f.current
+	}
+	f.subscribers[ch] = struct{}{}
+	return ch, func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		delete(f.subscribers, ch)
+	}
+}
 
-		t, err := template.New("visualization").Parse(tmpl)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+// serveEvents streams the graphs published to the client as Server-Sent
+// Events named graph, identified by their version. The version the client
+// has is given by the Last-Event-ID header when it reconnects, and by the
+// version parameter the first time.
+func (f *graphFeed) serveEvents(w http.ResponseWriter, r *http.Request) {
+	seen := r.Header.Get("Last-Event-ID")
+	if seen == "" {
+		seen = r.URL.Query().Get("version")
+	}
+	updates, unsubscribe := f.subscribe(seen)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+	for {
+		select {
+		case <-r.Context().Done():
 			return
+		case update := <-updates:
+			data, err := json.Marshal(update.Graph)
+			if err != nil {
+				return
+			}
+			fmt.Fprintf(w, "id: %d\nevent: graph\ndata: %s\n\n", update.Version, data)
+			if err := rc.Flush(); err != nil {
+				return
+			}
 		}
+	}
+}
+
+// newWebMux returns the handler of the interactive visualization of the
+// graph of feed. With watch, pages follow the graphs published to feed and
+// redraw themselves.
+func newWebMux(feed *graphFeed, watch bool) (http.Handler, error) {
+	static, err := fs.Sub(webFiles, "web/static")
+	if err != nil {
+		return nil, err
+	}
+	_, err = fs.Stat(webFiles, visNetwork)
+	vendored := err == nil
+	mux := http.NewServeMux()
+	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServerFS(static)))
+	if watch {
+		mux.HandleFunc("/events", feed.serveEvents)
+	}
 
-		nodesJSON, _ := json.Marshal(graph.Nodes)
-		edgesJSON, _ := json.Marshal(graph.Edges)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		current := feed.latest()
+		nodesJSON, _ := json.Marshal(current.Graph.Nodes)
+		edgesJSON, _ := json.Marshal(current.Graph.Edges)
 
 		data := struct {
-			Nodes template.JS
-			Edges template.JS
+			Nodes      template.JS
+			Edges      template.JS
+			Watch      bool
+			Version    int
+			VisNetwork bool
 		}{
-			Nodes: template.JS(nodesJSON),
-			Edges: template.JS(edgesJSON),
+			Nodes:      template.JS(nodesJSON),
+			Edges:      template.JS(edgesJSON),
+			Watch:      watch,
+			Version:    current.Version,
+			VisNetwork: vendored,
 		}
 
-		if err := t.Execute(w, data); err != nil {
+		if err := pageTemplate.Execute(w, data); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
 	})
+	return mux, nil
+}
+
+// startWebServer serves the interactive visualization of the graph of feed
+// on addr until the server fails or ctx is done. With watch, pages follow
+// the graphs published to feed and redraw themselves.
+func startWebServer(ctx context.Context, feed *graphFeed, addr string, watch bool) error {
+	mux, err := newWebMux(feed, watch)
+	if err != nil {
+		return err
+	}
 
-	fmt.Println("\nStarting web server on http://localhost:8080")
+	host := addr
+	if strings.HasPrefix(host, ":") {
+		host = "localhost" + host
+	}
+	fmt.Printf("Starting web server on http://%s\n", host)
 	fmt.Println("Open your browser to view the interactive visualization")
-	if err := http.ListenAndServe(":8080", nil); err != nil {
-		fmt.Printf("Error starting server: %v\n", err)
+	server := &http.Server{
+		Addr:    addr,
+		Handler: mux,
+		// Requests end with ctx, so that event streams do not hold up
+		// the shutdown.
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		server.Shutdown(context.Background())
+	}()
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		return err
 	}
+	return nil
 } 
\ No newline at end of file
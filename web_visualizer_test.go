@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"channeling/chanflow"
+)
+
+func TestWebGraphColors(t *testing.T) {
+	report := &chanflow.Report{Channels: []*chanflow.ChannelInfo{
+		{ID: "ok", Name: "ok"},
+		{ID: "stuck", Name: "stuck", Diagnostics: []chanflow.Diagnostic{{Rule: chanflow.RuleDeadlock}}},
+		{ID: "twice", Name: "twice", Diagnostics: []chanflow.Diagnostic{{Rule: chanflow.RuleDoubleClose}}},
+		{ID: "leaky", Name: "leaky", Diagnostics: []chanflow.Diagnostic{{Rule: chanflow.RuleLeak}}},
+	}}
+	want := map[string]string{
+		"ok":    statusColors["normal"],
+		"stuck": statusColors["deadlock"],
+		"twice": statusColors["close-panic"],
+		"leaky": statusColors["leak"],
+	}
+	for _, node := range generateWebGraph(report).Nodes {
+		if node.Color.Background != want[node.ID] {
+			t.Errorf("node %s with status %s has background %q, want %q", node.ID, node.Status, node.Color.Background, want[node.ID])
+		}
+	}
+}
+
+// assetPattern matches the scripts and style sheets a page loads.
+var assetPattern = regexp.MustCompile(`(?:src|href)="(/static/[^"]+)"`)
+
+func TestWebAssets(t *testing.T) {
+	mux, err := newWebMux(newGraphFeed(WebGraph{}), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	page := get("/")
+	if page.Code != http.StatusOK {
+		t.Fatalf("GET / = %d, want %d", page.Code, http.StatusOK)
+	}
+	body, _ := io.ReadAll(page.Body)
+	assets := assetPattern.FindAllStringSubmatch(string(body), -1)
+	if len(assets) == 0 {
+		t.Fatal("the page loads no scripts or style sheets")
+	}
+	for _, asset := range assets {
+		if code := get(asset[1]).Code; code != http.StatusOK {
+			t.Errorf("GET %s = %d, want %d", asset[1], code, http.StatusOK)
+		}
+	}
+
+	t.Run("vis-network", func(t *testing.T) {
+		if _, err := fs.Stat(webFiles, visNetwork); err != nil {
+			t.Skipf("%s is not vendored: run make web-assets", visNetwork)
+		}
+		if code := get("/static/vis-network/vis-network.min.js").Code; code != http.StatusOK {
+			t.Errorf("GET /static/vis-network/vis-network.min.js = %d, want %d", code, http.StatusOK)
+		}
+	})
+}
+
+// serverEvent is an event read from a Server-Sent Events stream.
+type serverEvent struct {
+	id, name, data string
+}
+
+// readEvent reads the next event of stream.
+func readEvent(t *testing.T, stream *bufio.Reader) serverEvent {
+	t.Helper()
+	var event serverEvent
+	for {
+		line, err := stream.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading the event stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return event
+		}
+		field, value, _ := strings.Cut(line, ": ")
+		switch field {
+		case "id":
+			event.id = value
+		case "event":
+			event.name = value
+		case "data":
+			event.data = value
+		}
+	}
+}
+
+func TestGraphEvents(t *testing.T) {
+	feed := newGraphFeed(WebGraph{Nodes: []WebNode{{ID: "v0"}}})
+	mux, err := newWebMux(feed, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// subscribe follows /events with the given query and Last-Event-ID.
+	subscribe := func(query, lastID string) *bufio.Reader {
+		t.Helper()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/events"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lastID != "" {
+			req.Header.Set("Last-Event-ID", lastID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+			t.Fatalf("Content-Type = %q, want text/event-stream", got)
+		}
+		return bufio.NewReader(resp.Body)
+	}
+	// expect reads an event and checks it carries the graph of version id
+	// with the single node node.
+	expect := func(stream *bufio.Reader, id, node string) {
+		t.Helper()
+		event := readEvent(t, stream)
+		if event.id != id || event.name != "graph" {
+			t.Fatalf("got event %q with id %q, want graph with id %q", event.name, event.id, id)
+		}
+		var graph WebGraph
+		if err := json.Unmarshal([]byte(event.data), &graph); err != nil {
+			t.Fatalf("event %s: %v", id, err)
+		}
+		if len(graph.Nodes) != 1 || graph.Nodes[0].ID != node {
+			t.Fatalf("event %s has nodes %v, want %s", id, graph.Nodes, node)
+		}
+	}
+
+	stream := subscribe("?version=0", "")
+	feed.publish(WebGraph{Nodes: []WebNode{{ID: "v1"}}})
+	expect(stream, "1", "v1")
+	feed.publish(WebGraph{Nodes: []WebNode{{ID: "v2"}}})
+	expect(stream, "2", "v2")
+
+	// A client resuming after version 1 gets version 2 right away, one
+	// resuming after version 2 only the graphs published after it.
+	expect(subscribe("", "1"), "2", "v2")
+	current := subscribe("?version=0", "2")
+	feed.publish(WebGraph{Nodes: []WebNode{{ID: "v3"}}})
+	expect(current, "3", "v3")
+}